@@ -0,0 +1,223 @@
+package charenc
+
+import (
+	"unicode/utf8"
+)
+
+// Variants of UTF-8 which can represent surrogates:
+//
+//	CESU-8: supplementary characters are written as surrogate pairs of three bytes sequences (Oracle UTF8)
+//	Java modified UTF-8: CESU-8 with lone surrogates and NUL written as C0 80 (class files, JNI, DataInput)
+//	WTF-8: UTF-8 with lone surrogates (Windows file names), paired surrogates must be written as four bytes
+//
+// Lone surrogates are decoded to runes U+D800..U+DFFF and encoded back to the same bytes. WTF-8 encoder keeps
+// high surrogate until the next rune or Flush call, so high and low surrogates are joined to four bytes sequence.
+
+type utf8_variant struct {
+	pairs bool   // Supplementary characters are written as surrogate pairs
+	lone  bool   // Lone surrogates are allowed
+	nul   bool   // NUL is written as C0 80
+	name  string // Canonical name of encoding
+}
+
+var (
+	cesu8 = utf8_variant{true, false, false, "CESU-8"}
+	mutf8 = utf8_variant{true, true, true, "MUTF-8"}
+	wtf8  = utf8_variant{false, true, false, "WTF-8"}
+)
+
+func utf8_new(variant *utf8_variant) func() CharacterEncoding {
+	return func() CharacterEncoding {
+		return &enc_CESU8{variant: variant}
+	}
+}
+
+type enc_CESU8 struct {
+	variant *utf8_variant
+	pending rune // High surrogate waiting for low one (WTF-8 encoder) or 0
+}
+
+func is_cont(b byte) bool {
+	return b & 0xc0 == 0x80
+}
+
+// Check if p can be the beginning of three bytes sequence of low surrogate
+func low_surrogate_prefix(p []byte) bool {
+	return (len(p) < 1 || p[0] == 0xed) && (len(p) < 2 || p[1] >= 0xb0 && p[1] <= 0xbf) && (len(p) < 3 || is_cont(p[2]))
+}
+
+func (self *enc_CESU8) DecodeRune(p []byte) (rune, int) {
+	return self.decode(p, false)
+}
+
+// Decode the rest of input: high surrogate at the end is lone one
+func (self *enc_CESU8) DecodeLast(p []byte) (rune, int) {
+	return self.decode(p, true)
+}
+
+func (self *enc_CESU8) decode(p []byte, last bool) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	switch b := p[0]; {
+	case b == 0 && self.variant.nul:
+		return RuneError, -1
+	case b == 0xc0 && self.variant.nul:
+		if len(p) < 2 {
+			return RuneError, 0
+		}
+		if p[1] != 0x80 {
+			return RuneError, -1
+		}
+		return 0, 2
+	case b != 0xed:
+		r, n := utf8.DecodeRune(p)
+		if r == RuneError && n <= 1 {
+			if !utf8.FullRune(p) {
+				return RuneError, 0
+			}
+			return RuneError, -1
+		}
+		if r >= 0x10000 && self.variant.pairs {
+			return RuneError, -n
+		}
+		return r, n
+	}
+
+	// ED: U+D000..U+DFFF including surrogates
+	if len(p) < 3 {
+		if len(p) == 2 && !is_cont(p[1]) {
+			return RuneError, -1
+		}
+		return RuneError, 0
+	}
+	if !is_cont(p[1]) || !is_cont(p[2]) {
+		return RuneError, -1
+	}
+
+	r := rune(p[1] & 0x3f) << 6 | rune(p[2] & 0x3f) | 0xd000
+	switch {
+	case r < 0xd800:
+		return r, 3
+	case r >= 0xdc00:
+		if !self.variant.lone {
+			return RuneError, -3
+		}
+		return r, 3
+	}
+
+	// High surrogate: check if low one follows
+	if len(p) < 6 && low_surrogate_prefix(p[3:]) && !last {
+		return RuneError, 0
+	}
+	if len(p) >= 6 && low_surrogate_prefix(p[3:6]) {
+		if !self.variant.pairs {
+			return RuneError, -6
+		}
+		low := rune(p[4] & 0x3f) << 6 | rune(p[5] & 0x3f) | 0xd000
+		return 0x10000 + (r - 0xd800) << 10 + (low - 0xdc00), 6
+	}
+	if !self.variant.lone {
+		return RuneError, -3
+	}
+
+	return r, 3
+}
+
+func (self *enc_CESU8) FullRune(p []byte) bool {
+	_, n := self.decode(p, false)
+	return n != 0
+}
+
+// Write three bytes sequence of character from U+0800..U+FFFF including surrogates
+func encode_rune3(p []byte, r rune) {
+	p[0] = 0xe0 | byte(r >> 12)
+	p[1] = 0x80 | byte(r >> 6) & 0x3f
+	p[2] = 0x80 | byte(r) & 0x3f
+}
+
+func (self *enc_CESU8) EncodeRune(p []byte, r rune) int {
+	n := 0
+	if self.pending != 0 {
+		if r >= 0xdc00 && r <= 0xdfff { // Surrogate pair is written as four bytes
+			n = enc_UTF8{}.EncodeRune(p, 0x10000 + (self.pending - 0xd800) << 10 + (r - 0xdc00))
+			if n > 0 {
+				self.pending = 0
+			}
+			return n
+		}
+
+		if len(p) < 3 {
+			return -1
+		}
+		encode_rune3(p, self.pending)
+		n = 3
+	}
+
+	if r >= 0xd800 && r <= 0xdbff && self.variant.lone && !self.variant.pairs {
+		self.pending = r
+		return n
+	}
+
+	l := self.encode(p[n:], r)
+	if l < 0 {
+		return -1
+	}
+
+	self.pending = 0
+	return n + l
+}
+
+func (self *enc_CESU8) encode(p []byte, r rune) int {
+	switch {
+	case r == 0 && self.variant.nul:
+		if len(p) < 2 {
+			return -1
+		}
+		p[0], p[1] = 0xc0, 0x80
+		return 2
+	case r >= 0xd800 && r <= 0xdfff:
+		if !self.variant.lone || len(p) < 3 {
+			return -1
+		}
+		encode_rune3(p, r)
+		return 3
+	case r >= 0x10000 && r <= 0x10ffff && self.variant.pairs:
+		if len(p) < 6 {
+			return -1
+		}
+		r -= 0x10000
+		encode_rune3(p, 0xd800 + r >> 10)
+		encode_rune3(p[3:], 0xdc00 + r & 0x3ff)
+		return 6
+	}
+
+	return enc_UTF8{}.EncodeRune(p, r)
+}
+
+// Write lone high surrogate at the end of text
+func (self *enc_CESU8) Flush(p []byte) int {
+	if self.pending == 0 {
+		return 0
+	}
+	if len(p) < 3 {
+		return -1
+	}
+
+	encode_rune3(p, self.pending)
+	self.pending = 0
+	return 3
+}
+
+func (self *enc_CESU8) Reset() {
+	self.pending = 0
+}
+
+func (self *enc_CESU8) save_state() interface{} {
+	return self.pending
+}
+
+func (self *enc_CESU8) restore_state(state interface{}) {
+	self.pending = state.(rune)
+}
@@ -1,23 +1,29 @@
 // Package charenc provides structures and functions to manipulate text encoded with a lot of encodings.
 // This package implemented with clean Go and doesn't use iconv.
-// Supported encodings includes IBM CP8??, Windows CP12??, MAC, KOI, UTF8/UTF16/UCS2/UCS4 encodings.
+// Supported encodings includes IBM CP8??, Windows CP12??, MAC, KOI, Shift_JIS, EUC-JP, GBK, GB18030, Big5, EUC-KR, ISO-2022, UTF7/UTF8/UTF16/UCS2/UCS4 encodings.
 package charenc
 
 import (
 	"unicode/utf8"
 	"strings"
-	"errors"
 	"bytes"
 )
 
 // RuneError is rune representing error value when returned. This contant has imported from utf8 package
 const RuneError rune = utf8.RuneError
 
+// NoRune is returned by stateful decoders (ISO-2022 for example) with length of escape or shift sequence
+// which doesn't represent any character.
+const NoRune rune = -1
+
 // RuneDecoder provides DecodeRune and FullRune functions.
 // DecodeRune method can be used to decode one rune from string.
 // p is original text.
 // Return values: resulting rune and len of this character in original text.
-// On error returns RuneError, 1
+// On error returns RuneError and negative length of invalid sequence (-1 for one invalid byte).
+// If p is empty or too short DecodeRune returns RuneError, 0
+// If one sequence represents two characters the first one is returned with 0 length and the second one on the next call.
+// Escape and shift sequences of stateful encodings are returned as NoRune.
 // FullRune method can be used to check if bytes starts from valid rune in this encoding
 type RuneDecoder interface {
 	DecodeRune(p []byte) (rune, int)
@@ -34,17 +40,31 @@ type RuneEncoder interface {
 	EncodeRune(p []byte, r rune) int
 }
 
+// StatefulDecoder is implemented by decoders which keep state between calls: detected byte order,
+// current character set or the second character of pair for example.
+// Reset method prepares decoder for the new stream. RuneReader and Reader call it when they are created.
+type StatefulDecoder interface {
+	RuneDecoder
+	Reset()
+}
+
+// StatefulEncoder is implemented by encoders which keep state between calls: written BOM, current character set
+// or character which can be combined with the next one for example.
+// Reset method prepares encoder for the new stream.
+// Flush method writes pending bytes at the end of text. It returns number of bytes written or -1 if p is too small.
+// Reader calls Flush at the end of input, RuneWriter and Writer call it from their Flush and Close methods.
+type StatefulEncoder interface {
+	RuneEncoder
+	Reset()
+	Flush(p []byte) int
+}
+
 // CharacterEncoding interface joins RuneEncoder and RuneDecoder into one interface for encoding and decoding runes from byte arrays
 type CharacterEncoding interface {
 	RuneDecoder
 	RuneEncoder
 }
 
-// IgnoreErrors indicates that errors must be ignored
-const IgnoreErrors int = 0x0001
-// ReplaceErrors indicated that invalid characters will be replaced by '?'
-const ReplaceErrors int = 0x0002
-
 /* Unicode encoders/decoders: */
 /* List of Unicodes supported by GNU iconv:
 UTF-8 
@@ -58,7 +78,11 @@ UTF-32, UTF-32BE, UTF-32LE
 type enc_UTF8 struct { }
 
 func (self enc_UTF8) DecodeRune(p []byte) (rune, int) {
-	return utf8.DecodeRune(p)
+	r, l := utf8.DecodeRune(p)
+	if r == RuneError && l == 1 {
+		return RuneError, -1
+	}
+	return r, l
 }
 
 func (self enc_UTF8) FullRune(p []byte) bool {
@@ -66,14 +90,15 @@ func (self enc_UTF8) FullRune(p []byte) bool {
 }
 
 func (self enc_UTF8) EncodeRune(p []byte, r rune) int {
+	if !utf8.ValidRune(r) { // Surrogates can not be encoded
+		return -1
+	}
 	buf := make([]byte, 8)
 	rv := utf8.EncodeRune(buf, r)
 	if rv > len(p) {
 		return -1
 	}
-	for i := range(buf) {
-		p[i] = buf[i]
-	}
+	copy(p, buf[:rv])
 	return rv
 }
 
@@ -116,6 +141,15 @@ func encode_rune(p []byte, r rune, be bool, size int) int {
 	return size
 }
 
+// UCS-2 can represent only characters from BMP:
+func encode_ucs2(p []byte, r rune, be bool) int {
+	if r < 0 || r > 0xffff {
+		return -1
+	}
+
+	return encode_rune(p, r, be, 2)
+}
+
 type enc_UCS2LE struct { }
 
 func (self enc_UCS2LE) DecodeRune(p []byte) (rune, int) {
@@ -127,7 +161,7 @@ func (self enc_UCS2LE) FullRune(p []byte) bool {
 }
 
 func (self enc_UCS2LE) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, false, 2)
+	return encode_ucs2(p, r, false)
 }
 
 func get_UCS2LE() CharacterEncoding {
@@ -145,50 +179,13 @@ func (self enc_UCS2BE) FullRune(p []byte) bool {
 }
 
 func (self enc_UCS2BE) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, true, 2)
+	return encode_ucs2(p, r, true)
 }
 
 func get_UCS2BE() CharacterEncoding {
 	return enc_UCS2BE{}
 }
 
-type enc_UCS2 struct {
-	endian int // Endian can be 0 - not detected (LE), 1 - LE, 2 - BE
-}
-
-func (self enc_UCS2) DecodeRune(p []byte) (rune, int) {
-	if self.endian == 0 {
-		if len(p) > 2 {
-			if p[0] == 0xFE && p[1] == 0xFF {
-				self.endian = 2
-			} else if p[0] == 0xFF && p[1] == 0xFE {
-				self.endian = 1
-			}
-		}
-
-		if (self.endian > 0) {
-			r, l := decode_rune(p[2:], self.endian == 2, 2)
-			return r, l + 2
-		}
-
-		self.endian = 2 // This is default
-	}
-
-	return decode_rune(p[2:], self.endian == 2, 2)
-}
-
-func (self enc_UCS2) FullRune(p []byte) bool {
-	return len(p) >= 2
-}
-
-func (self enc_UCS2) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, true, 2)
-}
-
-func get_UCS2() CharacterEncoding {
-	return enc_UCS2{0}
-}
-
 type enc_UCS4LE struct { }
 
 func (self enc_UCS4LE) DecodeRune(p []byte) (rune, int) {
@@ -225,56 +222,84 @@ func get_UCS4BE() CharacterEncoding {
 	return enc_UCS4BE{}
 }
 
-type enc_UCS4 struct {
-	endian int // 0 - start, 1 - LE, 2 - BE
-}
+// Surrogate pairs support for UTF-16:
+const (
+	surr1 = 0xd800 // High surrogates start
+	surr2 = 0xdc00 // Low surrogates start
+	surr3 = 0xe000 // End of surrogates
+	surrSelf = 0x10000
+)
 
-func (self enc_UCS4) DecodeRune(p []byte) (rune, int) {
-	if self.endian == 0 {
-		if len(p) > 4 {
-			if p[0] == 0 && p[1] == 0 && p[2] == 0xFE && p[3] == 0xFF {
-				self.endian = 2
-			} else if (p[0] == 0xFF && p[1] == 0xFE && p[2] == 0x00 && p[3] == 0x00) {
-				self.endian = 1
-			}
+// Decode one UTF-16 character (one or two 16bit words):
+func decode_utf16(p []byte, be bool) (rune, int) {
+	r1, l := decode_rune(p, be, 2)
+	if l == 0 {
+		return RuneError, 0
+	}
 
-			if self.endian > 0 {
-				r, l := decode_rune(p[4:], self.endian == 2, 4)
-				return r, l + 4
-			}
+	if r1 < surr1 || r1 >= surr3 {
+		return r1, 2
+	}
 
-			self.endian = 2 // This is default
-		}
+	if r1 >= surr2 { // Low surrogate without high one
+		return RuneError, -2
 	}
 
-	return decode_rune(p, self.endian == 2, 4)
-}
+	r2, l := decode_rune(p[2:], be, 2)
+	if l == 0 || r2 < surr2 || r2 >= surr3 { // High surrogate without low one
+		return RuneError, -2
+	}
 
-func (self enc_UCS4) FullRune(p []byte) bool {
-	return len(p) >= 4
+	return ((r1 - surr1) << 10 | (r2 - surr2)) + surrSelf, 4
 }
 
-func (self enc_UCS4) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, true, 4)
+// Check if p contains full UTF-16 character. Single high surrogate is not full rune.
+func full_utf16(p []byte, be bool) bool {
+	if len(p) < 2 {
+		return false
+	}
+
+	r, _ := decode_rune(p, be, 2)
+	if r >= surr1 && r < surr2 {
+		return len(p) >= 4
+	}
+
+	return true
 }
 
-func get_UCS4() CharacterEncoding {
-	return enc_UCS4{0}
+// Encode one character to UTF-16. Characters outside BMP are encoded as surrogate pairs.
+func encode_utf16(p []byte, r rune, be bool) int {
+	if r < 0 || r > utf8.MaxRune || (r >= surr1 && r < surr3) {
+		return -1
+	}
+
+	if r < surrSelf {
+		return encode_rune(p, r, be, 2)
+	}
+
+	if len(p) < 4 {
+		return -1
+	}
+
+	r -= surrSelf
+	encode_rune(p, surr1 + ((r >> 10) & 0x3ff), be, 2)
+	encode_rune(p[2:], surr2 + (r & 0x3ff), be, 2)
+
+	return 4
 }
 
-/* TODO: we need to make real UTF-16 encoder/decoder, this is only UCS2 */
 type enc_UTF16LE struct { }
 
 func (self enc_UTF16LE) DecodeRune(p []byte) (rune, int) {
-	return decode_rune(p, false, 2)
+	return decode_utf16(p, false)
 }
 
 func (self enc_UTF16LE) FullRune(p []byte) bool {
-	return len(p) >= 2
+	return full_utf16(p, false)
 }
 
 func (self enc_UTF16LE) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, false, 2)
+	return encode_utf16(p, r, false)
 }
 
 func get_UTF16LE() CharacterEncoding {
@@ -284,44 +309,279 @@ func get_UTF16LE() CharacterEncoding {
 type enc_UTF16BE struct { }
 
 func (self enc_UTF16BE) DecodeRune(p []byte) (rune, int) {
-	return decode_rune(p, true, 2)
+	return decode_utf16(p, true)
 }
 
 func (self enc_UTF16BE) FullRune(p []byte) bool {
-	return len(p) >= 2
+	return full_utf16(p, true)
 }
 
 func (self enc_UTF16BE) EncodeRune(p []byte, r rune) int {
-	return encode_rune(p, r, true, 2)
+	return encode_utf16(p, r, true)
 }
 
 func get_UTF16BE() CharacterEncoding {
 	return enc_UTF16BE{}
 }
 
-type init_unicode func () CharacterEncoding
+// Byte order detection for UCS-2, UCS-4, UTF-16 and UTF-32 encodings.
+// Decoder detects byte order using BOM at the start of stream and remembers it for the rest of stream.
+// Encoder writes BOM (big endian) before the first character.
+type byte_order struct {
+	size   int  // Size of BOM: 2 or 4 bytes
+	endian int  // Endian can be 0 - not detected, 1 - LE, 2 - BE
+	bom    bool // Encoder has written BOM already
+}
+
+// Check if p starts with BOM. Returns 0 - no BOM, 1 - LE, 2 - BE
+func (self *byte_order) check(p []byte) int {
+	if len(p) < self.size {
+		return 0
+	}
+
+	if r, _ := decode_rune(p, true, self.size); r == 0xfeff {
+		return 2
+	}
+
+	if r, _ := decode_rune(p, false, self.size); r == 0xfeff {
+		return 1
+	}
+
+	return 0
+}
+
+// Detect byte order. Returns length of BOM in p and true for big endian
+func (self *byte_order) detect(p []byte) (int, bool) {
+	if self.endian != 0 {
+		return 0, self.endian == 2
+	}
+
+	switch self.check(p) {
+	case 1:
+		return self.size, false
+	case 2:
+		return self.size, true
+	}
+
+	return 0, true // This is default
+}
+
+// Remember byte order after BOM or the first character was decoded
+func (self *byte_order) update(be bool) {
+	if be {
+		self.endian = 2
+	} else {
+		self.endian = 1
+	}
+}
+
+// Reset state for the new stream
+func (self *byte_order) Reset() {
+	self.endian = 0
+	self.bom = false
+}
+
+type enc_BOM struct {
+	byte_order
+	decode func(p []byte, be bool) (rune, int)
+	full   func(p []byte, be bool) bool
+	encode func(p []byte, r rune, be bool) int
+	name   string
+}
+
+func (self *enc_BOM) DecodeRune(p []byte) (rune, int) {
+	n, be := self.detect(p)
+	if n > 0 { // BOM is decoded separately, so stream containing only BOM is empty
+		self.update(be)
+		return NoRune, n
+	}
+
+	r, l := self.decode(p, be)
+	if l != 0 {
+		self.update(be)
+	}
+	return r, l
+}
+
+func (self *enc_BOM) FullRune(p []byte) bool {
+	n, be := self.detect(p)
+	return n > 0 || self.full(p, be)
+}
+
+func (self *enc_BOM) EncodeRune(p []byte, r rune) int {
+	n := 0
+	if !self.bom {
+		n = encode_rune(p, 0xfeff, true, self.size)
+		if n < 0 {
+			return -1
+		}
+	}
+
+	l := self.encode(p[n:], r, true)
+	if l < 0 {
+		return -1
+	}
+
+	self.bom = true
+	return l + n
+}
+
+// BOM is written with the first character so there is nothing to write at the end
+func (self *enc_BOM) Flush(p []byte) int {
+	return 0
+}
+
+func (self *enc_BOM) save_state() interface{} {
+	return self.bom
+}
+
+func (self *enc_BOM) restore_state(state interface{}) {
+	self.bom = state.(bool)
+}
+
+func decode_ucs2(p []byte, be bool) (rune, int) {
+	return decode_rune(p, be, 2)
+}
+
+func full_ucs2(p []byte, be bool) bool {
+	return len(p) >= 2
+}
+
+func decode_ucs4(p []byte, be bool) (rune, int) {
+	return decode_rune(p, be, 4)
+}
+
+func full_ucs4(p []byte, be bool) bool {
+	return len(p) >= 4
+}
+
+func encode_ucs4(p []byte, r rune, be bool) int {
+	return encode_rune(p, r, be, 4)
+}
+
+// UTF-32 is UCS-4 limited to valid Unicode characters:
+func decode_utf32(p []byte, be bool) (rune, int) {
+	r, l := decode_rune(p, be, 4)
+	if l > 0 && (r < 0 || r > utf8.MaxRune || (r >= surr1 && r < surr3)) {
+		return RuneError, -4
+	}
+
+	return r, l
+}
+
+func encode_utf32(p []byte, r rune, be bool) int {
+	if r < 0 || r > utf8.MaxRune || (r >= surr1 && r < surr3) {
+		return -1
+	}
+
+	return encode_rune(p, r, be, 4)
+}
+
+func get_UCS2() CharacterEncoding {
+	return &enc_BOM{byte_order{size: 2}, decode_ucs2, full_ucs2, encode_ucs2, "UCS-2"}
+}
+
+func get_UCS4() CharacterEncoding {
+	return &enc_BOM{byte_order{size: 4}, decode_ucs4, full_ucs4, encode_ucs4, "UCS-4"}
+}
+
+func get_UTF16() CharacterEncoding {
+	return &enc_BOM{byte_order{size: 2}, decode_utf16, full_utf16, encode_utf16, "UTF-16"}
+}
+
+func get_UTF32() CharacterEncoding {
+	return &enc_BOM{byte_order{size: 4}, decode_utf32, full_ucs4, encode_utf32, "UTF-32"}
+}
+
+// Reset decoder state if decoder has one
+func reset_decoder(decoder RuneDecoder) {
+	if d, ok := decoder.(StatefulDecoder); ok {
+		d.Reset()
+	}
+}
+
+// Decoders which look ahead implement DecodeLast to decode the rest of input at the end of stream
+// (Java modified UTF-8 checks if high surrogate is followed by low one for example).
+type last_decoder interface {
+	DecodeLast(p []byte) (rune, int)
+}
+
+// Decode the first character of the rest of input which is incomplete for DecodeRune
+func decode_last(decoder RuneDecoder, p []byte) (rune, int) {
+	if d, ok := decoder.(last_decoder); ok {
+		if r, n := d.DecodeLast(p); n != 0 {
+			return r, n
+		}
+	}
+
+	return RuneError, -len(p)
+}
+
+// Reset encoder state if encoder has one
+func reset_encoder(encoder RuneEncoder) {
+	if e, ok := encoder.(StatefulEncoder); ok {
+		e.Reset()
+	}
+}
+
+// Write final bytes of encoder if it has any
+func flush(encoder RuneEncoder, p []byte) int {
+	if e, ok := encoder.(StatefulEncoder); ok {
+		return e.Flush(p)
+	}
+
+	return 0
+}
 
-var unicode = map[string]init_unicode{
-	"UTF-8": get_UTF8,
-	"UTF8": get_UTF8,
-	"UCS2": get_UCS2,
-	"UCS2LE": get_UCS2LE,
-	"UCS2BE": get_UCS2BE,
-	"UCS4": get_UCS4,
-	"UCS4LE": get_UCS4LE,
-	"UCS4BE": get_UCS4BE,
-	"UTF32LE": get_UCS4LE,
-	"UTF32BE": get_UCS4BE,
-	"UTF-32LE": get_UCS4LE,
-	"UTF-32BE": get_UCS4BE,
-	"UTF-16LE": get_UTF16LE,
-	"UTF-16BE": get_UTF16BE,
-	"UTF16LE": get_UTF16LE,
-	"UTF16BE": get_UTF16BE,
+// Stateful encoders implement state_encoder, so wrappers can try to encode characters and roll back
+// (transliteration which fails in the middle of replacement for example).
+type state_encoder interface {
+	save_state() interface{}
+	restore_state(state interface{})
+}
+
+// Save state of encoder. Returns nil for stateless encoders.
+func save_encoder(encoder RuneEncoder) interface{} {
+	if e, ok := encoder.(state_encoder); ok {
+		return e.save_state()
+	}
+
+	return nil
+}
+
+// Restore state saved by save_encoder
+func restore_encoder(encoder RuneEncoder, state interface{}) {
+	if e, ok := encoder.(state_encoder); ok {
+		e.restore_state(state)
+	}
+}
+
+// Unicode encodings. UTF-16, UTF-32, UCS-2 and UCS-4 detect byte order by BOM and write it.
+var unicode_encodings = []Encoding{
+	{"UTF-8", []string{"UTF8"}, 106, "UTF-8", MultiByte, "Unicode UTF-8", get_UTF8},
+	{"CESU-8", []string{"CESU8", "CSCESU8"}, 1016, "", MultiByte,
+		"Unicode UTF-8 with supplementary characters as surrogate pairs", utf8_new(&cesu8)},
+	{"MUTF-8", []string{"MUTF8", "JAVA-MODIFIED-UTF-8", "MODIFIED-UTF-8"}, 0, "", MultiByte,
+		"Java modified UTF-8 (CESU-8 with NUL as C0 80)", utf8_new(&mutf8)},
+	{"WTF-8", []string{"WTF8"}, 0, "", MultiByte, "Unicode UTF-8 with lone surrogates", utf8_new(&wtf8)},
+	{"UTF-EBCDIC", []string{"UTFEBCDIC"}, 0, "", MultiByte, "Unicode UTF-8-Mod for EBCDIC systems (UTR #16)", get_UTF_EBCDIC},
+	{"UTF-16", []string{"UTF16"}, 1015, "", Stateful, "Unicode UTF-16 with byte order mark", get_UTF16},
+	{"UTF-16LE", []string{"UTF16LE"}, 1014, "UTF-16LE", MultiByte, "Unicode UTF-16 little endian", get_UTF16LE},
+	{"UTF-16BE", []string{"UTF16BE"}, 1013, "UTF-16BE", MultiByte, "Unicode UTF-16 big endian", get_UTF16BE},
+	{"UTF-32", []string{"UTF32"}, 1017, "", Stateful, "Unicode UTF-32 with byte order mark", get_UTF32},
+	{"UTF-32LE", []string{"UTF32LE"}, 1019, "", MultiByte, "Unicode UTF-32 little endian", get_UCS4LE},
+	{"UTF-32BE", []string{"UTF32BE"}, 1018, "", MultiByte, "Unicode UTF-32 big endian", get_UCS4BE},
+	{"UCS-2", []string{"UCS2"}, 1000, "", Stateful, "Unicode BMP in two bytes with byte order mark", get_UCS2},
+	{"UCS-2LE", []string{"UCS2LE"}, 0, "", MultiByte, "Unicode BMP in two bytes little endian", get_UCS2LE},
+	{"UCS-2BE", []string{"UCS2BE"}, 0, "", MultiByte, "Unicode BMP in two bytes big endian", get_UCS2BE},
+	{"UCS-4", []string{"UCS4"}, 1001, "", Stateful, "Unicode in four bytes with byte order mark", get_UCS4},
+	{"UCS-4LE", []string{"UCS4LE"}, 0, "", MultiByte, "Unicode in four bytes little endian", get_UCS4LE},
+	{"UCS-4BE", []string{"UCS4BE"}, 0, "", MultiByte, "Unicode in four bytes big endian", get_UCS4BE},
 }
 
 type bit8 struct {
 	id int
+	bestfit bool // Use Windows best fit table for characters which are not in encoding
 }
 
 func (self bit8) DecodeRune(p []byte) (rune, int) {
@@ -330,8 +590,8 @@ func (self bit8) DecodeRune(p []byte) (rune, int) {
 	}
 
 	r := ByteToRune(self.id, p[0])
-	if r == 0 {
-		return RuneError, 1
+	if r == 0 && p[0] != 0 {
+		return RuneError, -1
 	}
 
 	return r, 1
@@ -346,8 +606,13 @@ func (self bit8) EncodeRune(p []byte, r rune) int {
 		return -1
 	}
 
-	b := RuneToByte(self.id, r)
-	if b == 0 {
+	var b byte
+	if self.bestfit {
+		b = RuneToByteBestFit(self.id, r)
+	} else {
+		b = RuneToByte(self.id, r)
+	}
+	if b == 0 && r != 0 {
 		return -1
 	}
 
@@ -355,79 +620,339 @@ func (self bit8) EncodeRune(p []byte, r rune) int {
 	return 1
 }
 
-func NewRuneDecoder(encoding string) RuneDecoder {
-	// 1. Try to create unicode decoder, then 8-bit decoder wrap them on success to error checker
-	enc := strings.ToUpper(encoding)
-	init, e := unicode[enc]
-	if e {
-		f := init()
-		if f == nil {
-			return nil
+// Multibyte encodings (Shift_JIS, CP932, ...) use tables from tablesmb.go
+type mbcs struct {
+	id int
+}
+
+// Read code of the first character in p. Returns code and length of sequence (0 if p is too short, -1 for invalid byte)
+func (self mbcs) code(p []byte) (uint32, int) {
+	if len(p) < 1 {
+		return 0, 0
+	}
+
+	n := mb_seqlen(self.id, p[0])
+	if n == 0 {
+		return 0, -1
+	}
+	if len(p) < n {
+		return 0, 0
+	}
+
+	var code uint32 = 0
+	for i := 0; i < n; i++ {
+		code = code << 8 | uint32(p[i])
+	}
+
+	return code, n
+}
+
+// Length of invalid sequence. ASCII characters are never trail bytes so we must not skip them.
+func invalid_seq(p []byte, n int) int {
+	for i := 1; i < n; i++ {
+		if p[i] < 0x80 {
+			return -i
 		}
-		return f
 	}
 
-	// Not found. Try to find 8-bit encoding
-	id := Open8bit(encoding)
-	if id >= 0 {
-		return bit8{id}
+	return -n
+}
+
+func (self mbcs) DecodeRune(p []byte) (rune, int) {
+	code, n := self.code(p)
+	if n <= 0 {
+		return RuneError, n
 	}
 
-	return nil
+	r, ok := mb_to_rune(self.id, code)
+	if ok {
+		return r, n
+	}
+
+	return RuneError, invalid_seq(p, n)
 }
 
-func NewRuneEncoder(encoding string) RuneEncoder {
-	// 1. Try to create unicode decoder, then 8-bit decoder wrap them on success to error checker
-	enc := strings.ToUpper(encoding)
-	init, e := unicode[enc]
-	if e {
-		f := init()
-		if f == nil {
-			return nil
+func (self mbcs) FullRune(p []byte) bool {
+	if len(p) < 1 {
+		return false
+	}
+
+	return len(p) >= mb_seqlen(self.id, p[0])
+}
+
+func (self mbcs) EncodeRune(p []byte, r rune) int {
+	code, ok := mb_from_rune(self.id, r)
+	if !ok {
+		return -1
+	}
+
+	return self.put(p, code)
+}
+
+// Write code to p. Returns number of bytes written or -1 if p is too small.
+func (self mbcs) put(p []byte, code uint32) int {
+	n := 1
+	for c := code >> 8; c != 0; c >>= 8 {
+		n++
+	}
+	if len(p) < n {
+		return -1
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		p[i] = byte(code)
+		code >>= 8
+	}
+
+	return n
+}
+
+// Codec for multibyte encodings with codes representing two characters (JIS X 0213 and HKSCS for example).
+// Decoder returns the first character without consuming input and the second one on the next call.
+// Encoder keeps character which can be the first one of pair until the next character or Flush call.
+type mbcs_comb struct {
+	mbcs
+	second  rune // Second character of the pair or 0
+	pending rune // Character waiting for the next one or 0
+}
+
+func (self *mbcs_comb) DecodeRune(p []byte) (rune, int) {
+	code, n := self.code(p)
+	if n <= 0 {
+		return RuneError, n
+	}
+
+	if self.second != 0 {
+		r := self.second
+		self.second = 0
+		return r, n
+	}
+
+	if r, ok := mb_to_rune(self.id, code); ok {
+		return r, n
+	}
+
+	if r, ok := mb_to_runes(self.id, code); ok {
+		self.second = r[1]
+		return r[0], 0
+	}
+
+	return RuneError, invalid_seq(p, n)
+}
+
+func (self *mbcs_comb) EncodeRune(p []byte, r rune) int {
+	if self.pending == 0 {
+		if mb_pair_first(self.id, r) && self.mbcs.EncodeRune(p, r) > 0 {
+			self.pending = r
+			return 0
 		}
-		return f
+
+		return self.mbcs.EncodeRune(p, r)
 	}
 
-	// Not found. Try to find 8-bit encoding
-	id := Open8bit(encoding)
-	if id >= 0 {
-		return bit8{id}
+	if code, ok := mb_from_runes(self.id, self.pending, r); ok {
+		n := self.put(p, code)
+		if n > 0 {
+			self.pending = 0
+		}
+		return n
 	}
 
-	return nil
+	n := self.mbcs.EncodeRune(p, self.pending)
+	if n < 0 {
+		return -1
+	}
+
+	if mb_pair_first(self.id, r) && self.mbcs.EncodeRune(p[n:], r) > 0 {
+		self.pending = r
+		return n
+	}
+
+	l := self.mbcs.EncodeRune(p[n:], r)
+	if l < 0 {
+		return -1
+	}
+
+	self.pending = 0
+	return n + l
+}
+
+func (self *mbcs_comb) Flush(p []byte) int {
+	if self.pending == 0 {
+		return 0
+	}
+
+	n := self.mbcs.EncodeRune(p, self.pending)
+	if n > 0 {
+		self.pending = 0
+	}
+
+	return n
+}
+
+func (self *mbcs_comb) Reset() {
+	self.second = 0
+	self.pending = 0
+}
+
+func (self *mbcs_comb) save_state() interface{} {
+	return self.pending
+}
+
+func (self *mbcs_comb) restore_state(state interface{}) {
+	self.pending = state.(rune)
+}
+
+// Create codec for multibyte encoding
+func open_mbcs(id int) CharacterEncoding {
+	switch mbnames[id].charset {
+	case "gb18030":
+		return enc_GB18030{mbcs{id}}
+	}
+
+	if mbnames[id].comb != nil {
+		return &mbcs_comb{mbcs: mbcs{id}}
+	}
+
+	return mbcs{id}
+}
+
+// Normalize name of character set: all non alphanumeric characters are replaced by '_'
+func charset_name(encoding string) string {
+	benc := []byte(encoding)
+	for i := range(benc) {
+		if !isalnum(benc[i]) {
+			benc[i] = '_'
+		}
+	}
+
+	return strings.ToUpper(string(benc))
+}
+
+// Create codec by encoding name without suffixes. EBCDIC encodings accept ",swaplfnl" option.
+func open_encoding(encoding string) CharacterEncoding {
+	name, swap := parse_swaplfnl(encoding)
+	e := Lookup(name)
+	if e == nil {
+		return nil
+	}
+	if swap {
+		return open_swaplfnl(e.New())
+	}
+
+	return e.New()
+}
+
+// NewRuneDecoder creates decoder for encoding. iconv suffix //IGNORE makes decoder skip invalid sequences
+// ("CP1251//IGNORE"), //TRANSLIT is accepted and has no effect for decoders.
+// EBCDIC encodings accept ICU option ",swaplfnl" which maps NL (0x15) to LF ("cp1047,swaplfnl//IGNORE").
+func NewRuneDecoder(encoding string) RuneDecoder {
+	name, _, ignore, ok := parse_suffixes(encoding)
+	if !ok {
+		return nil
+	}
+
+	decoder := open_encoding(name)
+	if decoder == nil {
+		return nil
+	}
+	if ignore {
+		return &ignore_decoder{decoder}
+	}
+
+	return decoder
+}
+
+// NewRuneEncoder creates encoder for encoding. iconv suffixes are supported: //TRANSLIT transliterates
+// characters which can not be encoded, //IGNORE skips them ("ASCII//TRANSLIT//IGNORE").
+func NewRuneEncoder(encoding string) RuneEncoder {
+	return new_encoder(encoding, false)
+}
+
+// NewBestFitEncoder creates encoder which maps characters missing in encoding to the closest ones
+// like WideCharToMultiByte does with best fit enabled (U+0101 to 'a' in cp1252 for example).
+// Best fit tables exist for cp125x, cp437, cp850 and cp866, other encodings work as NewRuneEncoder ones.
+func NewBestFitEncoder(encoding string) RuneEncoder {
+	return new_encoder(encoding, true)
+}
+
+func new_encoder(encoding string, bestfit bool) RuneEncoder {
+	name, translit, ignore, ok := parse_suffixes(encoding)
+	if !ok {
+		return nil
+	}
+
+	codec := open_encoding(name)
+	if codec == nil {
+		return nil
+	}
+
+	var encoder RuneEncoder = codec
+	if b, ok := codec.(bit8); ok && bestfit && names[b.id].bestfit != nil {
+		encoder = bit8{b.id, true}
+	}
+
+	if translit {
+		encoder = NewTranslitEncoder(encoder)
+	}
+	if ignore {
+		encoder = &ignore_encoder{encoder}
+	}
+
+	return encoder
 }
 
-/// Decode bytes to array of runes using specified characters encoding
+/// Decode bytes to array of runes using specified characters encoding. On error returns *DecodeError.
 func DecodeBytes(ctx RuneDecoder, s []byte) ([]rune, error) {
 	res := make([]rune, 0)
+	reset_decoder(ctx)
 
+	var at position
+	at.reset()
 	for pos := 0; pos < len(s); {
 		r, l := ctx.DecodeRune(s[pos:])
+		if l == 0 && r == RuneError {
+			r, l = decode_last(ctx, s[pos:])
+		}
 		if l < 0 {
-			return nil, errors.New("can not decode rune at position " + string(pos))
+			return nil, at.decode_error(ctx, s[pos:pos - l])
 		}
 
-		res = append(res, r)
+		if r != NoRune {
+			res = append(res, r)
+		}
+		at.advance(r, l)
+		pos += l
 	}
 
 	return res, nil
 }
 
-/// Encode runes to specified encoding
+/// Encode runes to specified encoding. On error returns *EncodeError.
 func EncodeRunes(ctx RuneEncoder, r []rune) ([]byte, error) {
-	b := make([]byte, len(r))
-	tmpbuf := make([]byte, 8) // I belive there will not be an charset with symbol more then 8 bytes
+	b := make([]byte, 0, len(r))
+	tmpbuf := make([]byte, 32) // Transliterated character can be longer then one symbol of any charset
 	buf := bytes.NewBuffer(b)
+	reset_encoder(ctx)
 
+	var at position
+	at.reset()
 	for i := range(r) {
 		l := ctx.EncodeRune(tmpbuf, r[i])
 		if l < 0 {
-			return nil, errors.New("can not encode run at position " + string(i))
+			return nil, at.encode_error(ctx, r[i])
 		}
 
 		buf.Write(tmpbuf[0:l])
+		at.advance(r[i], l)
 	}
 
+	l := flush(ctx, tmpbuf)
+	if l < 0 {
+		return nil, at.encode_error(ctx, NoRune)
+	}
+	buf.Write(tmpbuf[0:l])
+
 	return buf.Bytes(), nil
 }
 
@@ -441,26 +966,13 @@ func RunesToString(ctx RuneEncoder, r []rune) (string, error) {
 	return string(res), err
 }
 
-// ListEncodings list all supported character encodings:
+// ListEncodings list names and aliases of all encodings in DefaultRegistry:
 func ListEncodings() []string {
-	len_unicode := len(unicode)
-
-	l := len_unicode + len(names)
-
-	r := make([]string, l)
-
-	i := 0
-	for s := range(unicode) {
-		r[i] = s
-		i++
-	}
-
-	for j := range(names) {
-		r[i] = names[j].name
-		i++
+	var r []string
+	for _, e := range(DefaultRegistry.Encodings()) {
+		r = append(r, e.Name)
+		r = append(r, e.Aliases...)
 	}
 
 	return r
 }
-
-
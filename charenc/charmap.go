@@ -0,0 +1,356 @@
+package charenc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Charmap is encoding loaded from mapping file at runtime. Three formats are supported:
+//
+//	Unicode.org mapping files: "0x8140 0x3000 # comment", undefined codes have no second column
+//	glibc localedata charmaps: "<U3000> /x81/x40 IDEOGRAPHIC SPACE" between CHARMAP and END CHARMAP
+//	ICU .ucm files: "<U3000> \x81\x40 |0" between CHARMAP and END CHARMAP
+//
+// Sequences may have different lengths (up to 4 bytes) but no sequence can be the beginning of another one.
+// If several codes represent the same character the first one is used by encoder (ICU fallbacks |1 are
+// used by encoder only and reverse fallbacks |3 by decoder only). Charmap has no state so it can be shared.
+type Charmap struct {
+	name     string          // <code_set_name> of the file or registered name
+	aliases  []string        // glibc "% alias" comments
+	prefixes map[string]bool // beginnings of sequences
+	to_ucs   map[string]rune // by byte sequence
+	from_ucs map[rune]string
+}
+
+// Name returns <code_set_name> of charmap file or name charmap is registered with ("" if unknown)
+func (self *Charmap) Name() string {
+	return self.name
+}
+
+// Aliases returns aliases of glibc charmap ("% alias ISO-IR-100" lines of the header)
+func (self *Charmap) Aliases() []string {
+	return self.aliases
+}
+
+func (self *Charmap) DecodeRune(p []byte) (rune, int) {
+	for n := 1; n <= len(p); n++ {
+		if r, ok := self.to_ucs[string(p[:n])]; ok {
+			return r, n
+		}
+		if !self.prefixes[string(p[:n])] {
+			return RuneError, invalid_seq(p, n)
+		}
+	}
+
+	return RuneError, 0
+}
+
+func (self *Charmap) FullRune(p []byte) bool {
+	for n := 1; n <= len(p); n++ {
+		if !self.prefixes[string(p[:n])] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *Charmap) EncodeRune(p []byte, r rune) int {
+	s, ok := self.from_ucs[r]
+	if !ok || len(p) < len(s) {
+		return -1
+	}
+
+	return copy(p, s)
+}
+
+// Add mapping of sequence to character. dir is ICU precision indicator: 0 for round trip mapping,
+// 1 and 4 for encoder only, 3 for decoder only.
+func (self *Charmap) add(seq string, r rune, dir int) error {
+	if len(seq) == 0 || len(seq) > 4 {
+		return fmt.Errorf("invalid length of sequence % X", seq)
+	}
+	if self.prefixes[seq] {
+		return fmt.Errorf("sequence % X is the beginning of other sequence", seq)
+	}
+	for n := 1; n < len(seq); n++ {
+		if _, ok := self.to_ucs[seq[:n]]; ok {
+			return fmt.Errorf("sequence % X begins with sequence % X", seq, seq[:n])
+		}
+		self.prefixes[seq[:n]] = true
+	}
+
+	if dir != 1 && dir != 4 {
+		if _, ok := self.to_ucs[seq]; !ok {
+			self.to_ucs[seq] = r
+		}
+	}
+	if dir != 3 {
+		if _, ok := self.from_ucs[r]; !ok {
+			self.from_ucs[r] = seq
+		}
+	}
+
+	return nil
+}
+
+// LoadCharmap reads charmap from file, see Charmap for supported formats
+func LoadCharmap(path string) (*Charmap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cm, err := read_charmap(f)
+	if err != nil {
+		return nil, fmt.Errorf("charenc: invalid charmap %s: %s", path, err.Error())
+	}
+
+	return cm, nil
+}
+
+// ParseCharmap reads charmap in one of supported formats (see Charmap). Format is detected by content:
+// files with CHARMAP section are glibc charmaps or ICU .ucm files, other ones are Unicode.org mapping files.
+func ParseCharmap(r io.Reader) (*Charmap, error) {
+	cm, err := read_charmap(r)
+	if err != nil {
+		return nil, fmt.Errorf("charenc: invalid charmap: %s", err.Error())
+	}
+
+	return cm, nil
+}
+
+func read_charmap(r io.Reader) (*Charmap, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	section := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "CHARMAP" {
+			section = true
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cm := &Charmap{prefixes: make(map[string]bool), to_ucs: make(map[string]rune), from_ucs: make(map[rune]string)}
+	var err error
+	if section {
+		err = cm.parse_charmap(lines)
+	} else {
+		err = cm.parse_unicode_org(lines)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cm.to_ucs) == 0 {
+		return nil, fmt.Errorf("no mappings")
+	}
+
+	return cm, nil
+}
+
+// Unicode.org format: code and character in hexadecimal, everything after '#' is comment
+func (self *Charmap) parse_unicode_org(lines []string) error {
+	for i, line := range(lines) {
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue // Empty line or undefined code
+		}
+
+		seq, e1 := parse_hex_bytes(f[0])
+		r, e2 := parse_hex_rune(f[1])
+		if e1 != nil || e2 != nil {
+			return fmt.Errorf("line %d: invalid mapping %q", i + 1, strings.TrimSpace(line))
+		}
+		if err := self.add(seq, r, 0); err != nil {
+			return fmt.Errorf("line %d: %s", i + 1, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// "0x8140" is two bytes sequence
+func parse_hex_bytes(s string) (string, error) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return "", fmt.Errorf("invalid code %q", s)
+	}
+	s = s[2:]
+	if len(s) % 2 != 0 {
+		s = "0" + s
+	}
+
+	res := make([]byte, len(s) / 2)
+	for i := range(res) {
+		b, err := strconv.ParseUint(s[2 * i:2 * i + 2], 16, 8)
+		if err != nil {
+			return "", err
+		}
+		res[i] = byte(b)
+	}
+
+	return string(res), nil
+}
+
+func parse_hex_rune(s string) (rune, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || strings.HasPrefix(s, "U+") {
+		s = s[2:]
+	}
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || r > 0x10ffff {
+		return 0, fmt.Errorf("invalid character %q", s)
+	}
+
+	return rune(r), nil
+}
+
+// glibc charmap and ICU .ucm: header with <code_set_name>, <comment_char> and <escape_char> followed by
+// CHARMAP section. glibc ranges "<U3400>..<U4DB5> /x81/x30 <CJK>" map characters to sequential codes.
+func (self *Charmap) parse_charmap(lines []string) error {
+	comment, escape := "#", byte('/')
+	section := false
+	for i, line := range(lines) {
+		f := strings.Fields(line)
+		if !section && len(f) == 3 && f[0] == comment && f[1] == "alias" {
+			self.aliases = append(self.aliases, f[2])
+		}
+		if len(f) == 0 || strings.HasPrefix(f[0], comment) || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+
+		if !section {
+			switch {
+			case f[0] == "CHARMAP":
+				section = true
+			case f[0] == "<code_set_name>" && len(f) > 1:
+				self.name = strings.Trim(f[1], "\"")
+			case f[0] == "<comment_char>" && len(f) > 1:
+				comment = f[1]
+			case f[0] == "<escape_char>" && len(f) > 1:
+				escape = f[1][0]
+			}
+			continue
+		}
+		if f[0] == "END" {
+			break
+		}
+		if len(f) < 2 {
+			return fmt.Errorf("line %d: invalid mapping %q", i + 1, line)
+		}
+
+		first, last, ok := parse_charmap_chars(f[0])
+		if !ok {
+			continue // Symbolic names without code points and sequences of several characters are not supported
+		}
+		seq, ok := parse_charmap_bytes(f[1], escape)
+		if !ok {
+			return fmt.Errorf("line %d: invalid byte sequence %q", i + 1, f[1])
+		}
+
+		dir := 0
+		if len(f) > 2 && strings.HasPrefix(f[2], "|") {
+			dir, _ = strconv.Atoi(f[2][1:])
+			if dir == 2 {
+				continue // Mapping to subchar1
+			}
+		}
+
+		for r := first; r <= last; r++ {
+			if err := self.add(string(seq), r, dir); err != nil {
+				return fmt.Errorf("line %d: %s", i + 1, err.Error())
+			}
+			if r == last {
+				break
+			}
+			if seq[len(seq) - 1] == 0xff {
+				return fmt.Errorf("line %d: range %s is too long", i + 1, f[0])
+			}
+			seq[len(seq) - 1]++
+		}
+	}
+
+	return nil
+}
+
+// Parse "<U0041>" or range "<U3400>..<U4DB5>"
+func parse_charmap_chars(s string) (rune, rune, bool) {
+	parse := func(s string) (rune, bool) {
+		if !strings.HasPrefix(s, "<U") || !strings.HasSuffix(s, ">") {
+			return 0, false
+		}
+		r, err := parse_hex_rune(s[2:len(s) - 1])
+		return r, err == nil
+	}
+
+	a, b := s, s
+	if i := strings.Index(s, ".."); i > 0 {
+		a, b = s[:i], s[i + 2:]
+	}
+
+	first, ok1 := parse(a)
+	last, ok2 := parse(b)
+	if !ok1 || !ok2 || last < first {
+		return 0, 0, false
+	}
+
+	return first, last, true
+}
+
+// Parse bytes written as /xNN, /dNNN or /oNNN (escape character is '/' in glibc and '\' in ICU)
+func parse_charmap_bytes(s string, escape byte) ([]byte, bool) {
+	var res []byte
+	for len(s) > 0 {
+		if len(s) < 3 || (s[0] != escape && s[0] != '\\') {
+			return nil, false
+		}
+
+		base, digits := 16, 2
+		switch s[1] {
+		case 'x':
+		case 'd':
+			base, digits = 10, 3
+		case 'o':
+			base, digits = 8, 3
+		default:
+			return nil, false
+		}
+
+		n := 2
+		for n < len(s) && n < 2 + digits && s[n] != escape && s[n] != '\\' {
+			n++
+		}
+		b, err := strconv.ParseUint(s[2:n], base, 8)
+		if err != nil {
+			return nil, false
+		}
+		res = append(res, byte(b))
+		s = s[n:]
+	}
+
+	return res, len(res) > 0
+}
+
+// RegisterCharmap adds charmap to DefaultRegistry with name and aliases, so it can be used by NewRuneDecoder,
+// NewRuneEncoder, GetReader and other functions taking encoding name. Charmaps override built-in encodings.
+func RegisterCharmap(cm *Charmap, name string, aliases ...string) {
+	c := *cm
+	c.name = name
+	c.aliases = aliases
+
+	width := SingleByte
+	if len(c.prefixes) > 0 {
+		width = MultiByte
+	}
+	Register(Encoding{name, aliases, 0, "", width, "charmap", func() CharacterEncoding { return &c }})
+}
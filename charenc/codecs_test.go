@@ -0,0 +1,147 @@
+package charenc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Known encodings of text, most are outputs of Python codecs and glibc iconv
+var codec_tests = []struct {
+	encoding string
+	text     []rune
+	data     string
+}{
+	{"ISO-2022-JP", []rune("日本語 abc"), "\x1b$BF|K\\8l\x1b(B abc"},
+	{"ISO-2022-JP-2", []rune(" "), "\x1b.A\x1bN "}, // glibc: G2 set of 96 characters includes 0x20
+	{"ISO-2022-JP-2", []rune("Ωé中한"), "\x1b$B&8\x1b$(D+1\x1b$BCf\x1b$(CGQ\x1b(B"},
+	{"ISO-2022-JP-2004", []rune("\U00020089"), "\x1b$(P!!\x1b(B"},
+	{"ISO-2022-JP-2004", []rune("俱"), "\x1b$(Q.!\x1b(B"},
+	{"ISO-2022-KR", []rune("한국어 a"), "\x1b$)C\x0eGQ19>n\x0f a"},
+	{"ISO-2022-CN", []rune("中文\n中"), "\x1b$)A\x0eVPND\x0f\n\x1b$)A\x0eVP\x0f"}, // glibc: designation is reset at end of line
+	{"UTF-7", []rune("日本語"), "+ZeVnLIqe-"},
+	{"UTF-7", []rune("A≢Α."), "A+ImIDkQ."},
+	{"UTF-7", []rune("+1"), "+-1"},
+	{"UTF-7", []rune("\U0001f600"), "+2D3eAA-"},
+	{"UTF-7", []rune("Hi Mom -☺-!"), "Hi Mom -+Jjo--+ACE-"}, // Optional direct characters are encoded
+	{"UTF-7-IMAP", []rune("~peter/mail/台北/日本語"), "~peter/mail/&U,BTFw-/&ZeVnLIqe-"},
+	{"GB18030", []rune("é中"), "\xa8\xa6\xd6\xd0"},
+	{"GB18030", []rune("€"), "\xa2\xe3"},
+	{"GB18030", []rune("¥"), "\x81\x30\x84\x36"},
+	{"GB18030", []rune("￿"), "\x84\x31\xa4\x39"},
+	{"GB18030", []rune(""), "\xa3\xa0"},
+	{"GB18030", []rune("\U00010000"), "\x90\x30\x81\x30"},
+	{"CESU-8", []rune("\U00010400"), "\xed\xa0\x81\xed\xb0\x80"},
+	{"MUTF-8", []rune("\x00\U00010400"), "\xc0\x80\xed\xa0\x81\xed\xb0\x80"},
+	{"WTF-8", []rune{0xd800, 'a'}, "\xed\xa0\x80a"},
+}
+
+// Sequences which are decoded but never written by encoder
+var decode_tests = []struct {
+	encoding string
+	data     string
+	text     []rune
+}{
+	{"UTF-7", "Hi Mom -+Jjo--!", []rune("Hi Mom -☺-!")},
+	{"ISO-2022-CN", "\x1b$)A\x0eVP\nVP", []rune("中\nVP")},
+	{"ISO-2022-JP-2004", "\x1b$(P#!", []rune("儈")},
+}
+
+// Invalid sequences
+var decode_error_tests = []struct {
+	encoding string
+	data     string
+	invalid  string
+}{
+	{"ISO-2022-JP-2004", "\x1b$(P\x22\x21", "\x22\x21"}, // Row 2 is not in plane 2
+	{"UTF-7", "+Jjo\x80", "\x80"},
+	{"GB18030", "\x81\x30\x81", "\x81\x30\x81"},
+	{"CESU-8", "\xf0\x90\x90\x80", "\xf0\x90\x90\x80"},
+	{"MUTF-8", "\x00", "\x00"},
+	{"WTF-8", "\xed\xa0\x81\xed\xb0\x80", "\xed\xa0\x81\xed\xb0\x80"}, // Surrogate pair is written as 4 bytes sequence
+}
+
+// Runes which can not be encoded
+var encode_error_tests = []struct {
+	encoding string
+	r        rune
+}{
+	{"ISO-2022-JP", 0xff71},
+	{"ISO-2022-JP-2004", 0x010a},
+	{"ISO-2022-JP-3", 0x4ff1}, // Added in JIS X 0213:2004
+}
+
+func TestCodecs(t *testing.T) {
+	for _, x := range(codec_tests) {
+		data, err := EncodeRunes(NewRuneEncoder(x.encoding), x.text)
+		if err != nil || string(data) != x.data {
+			t.Errorf("%s: encode %q: got %q (%v), want %q", x.encoding, string(x.text), data, err, x.data)
+		}
+
+		text, err := DecodeBytes(NewRuneDecoder(x.encoding), []byte(x.data))
+		if err != nil || string(text) != string(x.text) {
+			t.Errorf("%s: decode %q: got %U (%v), want %U", x.encoding, x.data, text, err, x.text)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, x := range(decode_tests) {
+		text, err := DecodeBytes(NewRuneDecoder(x.encoding), []byte(x.data))
+		if err != nil || string(text) != string(x.text) {
+			t.Errorf("%s: decode %q: got %U (%v), want %U", x.encoding, x.data, text, err, x.text)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, x := range(decode_error_tests) {
+		_, err := DecodeBytes(NewRuneDecoder(x.encoding), []byte(x.data))
+		e, ok := err.(*DecodeError)
+		if !ok || !bytes.Equal(e.Bytes, []byte(x.invalid)) {
+			t.Errorf("%s: decode %q: got %v, want invalid sequence %q", x.encoding, x.data, err, x.invalid)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, x := range(encode_error_tests) {
+		_, err := EncodeRunes(NewRuneEncoder(x.encoding), []rune{x.r})
+		if e, ok := err.(*EncodeError); !ok || e.Rune != x.r {
+			t.Errorf("%s: encode %U: got %v, want encode error", x.encoding, x.r, err)
+		}
+	}
+}
+
+// WHATWG gb18030 uses GB18030-2022 index, GBK writes euro sign as 0x80
+var whatwg_tests = []struct {
+	label string
+	text  []rune
+	data  string
+}{
+	{"gb18030", []rune("ḿ"), "\xa8\xbc"},
+	{"gb18030", []rune(""), "\x81\x35\xf4\x37"},
+	{"gb18030", []rune("︐"), "\xa6\xd9"},
+	{"gb18030", []rune("€"), "\xa2\xe3"},
+	{"gbk", []rune("€中"), "\x80\xd6\xd0"},
+}
+
+func TestWHATWG(t *testing.T) {
+	for _, x := range(whatwg_tests) {
+		data, err := EncodeRunes(NewWHATWGEncoder(x.label), x.text)
+		if err != nil || string(data) != x.data {
+			t.Errorf("%s: encode %q: got %q (%v), want %q", x.label, string(x.text), data, err, x.data)
+		}
+
+		text, err := DecodeBytes(NewWHATWGDecoder(x.label), []byte(x.data))
+		if err != nil || string(text) != string(x.text) {
+			t.Errorf("%s: decode %q: got %U (%v), want %U", x.label, x.data, text, err, x.text)
+		}
+	}
+
+	if _, err := EncodeRunes(NewWHATWGEncoder("gbk"), []rune{0x10000}); err == nil {
+		t.Errorf("gbk: four bytes code is written")
+	}
+	if text, err := DecodeBytes(NewWHATWGDecoder("gb18030"), []byte("\x80")); err != nil || string(text) != "€" {
+		t.Errorf("gb18030: 0x80 is decoded as %U (%v)", text, err)
+	}
+}
@@ -0,0 +1,187 @@
+package charenc
+
+import (
+	"strings"
+)
+
+// UTF-EBCDIC (Unicode Technical Report #16): character is written in UTF-8-Mod (I8 sequence of one byte for
+// U+0000..U+009F and up to five bytes with five bits in every trailing byte) and each byte is mapped to EBCDIC.
+// Bytes 0x00..0x9F map to positions of U+0000..U+009F in cp1047, other 96 bytes map to the rest positions in
+// ascending order.
+
+var (
+	i8_to_ebcdic [256]byte
+	ebcdic_to_i8 [256]byte
+)
+
+func init() {
+	cp1047 := Open8bit("cp1047")
+	var rest []byte
+	for b := 0; b < 256; b++ {
+		r := ByteToRune(cp1047, byte(b))
+		if r < 0xa0 {
+			i8_to_ebcdic[r] = byte(b)
+		} else {
+			rest = append(rest, byte(b))
+		}
+	}
+	for i, b := range(rest) {
+		i8_to_ebcdic[0xa0 + i] = b
+	}
+	for i, b := range(i8_to_ebcdic) {
+		ebcdic_to_i8[b] = byte(i)
+	}
+}
+
+type enc_UTF_EBCDIC struct { }
+
+func get_UTF_EBCDIC() CharacterEncoding {
+	return enc_UTF_EBCDIC{}
+}
+
+func (self enc_UTF_EBCDIC) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := ebcdic_to_i8[p[0]]
+	n := 0
+	var r, min rune
+	switch {
+	case b < 0xa0:
+		return rune(b), 1
+	case b >= 0xc0 && b < 0xe0:
+		n, r, min = 2, rune(b & 0x1f), 0xa0
+	case b >= 0xe0 && b < 0xf0:
+		n, r, min = 3, rune(b & 0x0f), 0x400
+	case b >= 0xf0 && b < 0xf8:
+		n, r, min = 4, rune(b & 0x07), 0x4000
+	case b >= 0xf8 && b < 0xfc:
+		n, r, min = 5, rune(b & 0x03), 0x40000
+	default: // Trailing byte or sequence longer than five bytes
+		return RuneError, -1
+	}
+
+	for i := 1; i < n; i++ {
+		if i >= len(p) {
+			return RuneError, 0
+		}
+		t := ebcdic_to_i8[p[i]]
+		if t < 0xa0 || t >= 0xc0 {
+			return RuneError, -i
+		}
+		r = r << 5 | rune(t & 0x1f)
+	}
+
+	if r < min || r > 0x10ffff || r >= 0xd800 && r <= 0xdfff {
+		return RuneError, -n
+	}
+
+	return r, n
+}
+
+func (self enc_UTF_EBCDIC) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+func (self enc_UTF_EBCDIC) EncodeRune(p []byte, r rune) int {
+	if r < 0 || r > 0x10ffff || r >= 0xd800 && r <= 0xdfff {
+		return -1
+	}
+	if r < 0xa0 {
+		if len(p) < 1 {
+			return -1
+		}
+		p[0] = i8_to_ebcdic[r]
+		return 1
+	}
+
+	n := 5
+	switch {
+	case r < 0x400:
+		n = 2
+	case r < 0x4000:
+		n = 3
+	case r < 0x40000:
+		n = 4
+	}
+	if len(p) < n {
+		return -1
+	}
+
+	for i := n - 1; i > 0; i-- {
+		p[i] = i8_to_ebcdic[0xa0 | r & 0x1f]
+		r >>= 5
+	}
+	p[0] = i8_to_ebcdic[byte(0xff << uint(8 - n)) | byte(r)]
+
+	return n
+}
+
+// EBCDIC has NL (0x15, U+0085) and LF (0x25, U+000A) but text files use NL. ICU option ",swaplfnl" of encoding
+// name ("cp1047,swaplfnl") swaps them, so 0x15 is decoded to U+000A and U+000A is encoded to 0x15.
+const swaplfnl = ",swaplfnl"
+
+type swap_lfnl struct {
+	codec CharacterEncoding
+}
+
+// Split ",swaplfnl" option from encoding name
+func parse_swaplfnl(encoding string) (string, bool) {
+	if len(encoding) > len(swaplfnl) && strings.EqualFold(encoding[len(encoding) - len(swaplfnl):], swaplfnl) {
+		return encoding[:len(encoding) - len(swaplfnl)], true
+	}
+
+	return encoding, false
+}
+
+// Wrap EBCDIC codec, returns nil if codec doesn't decode 0x15 as NL
+func open_swaplfnl(codec CharacterEncoding) CharacterEncoding {
+	if r, n := codec.DecodeRune([]byte{0x15}); r != 0x85 || n != 1 {
+		return nil
+	}
+
+	return &swap_lfnl{codec}
+}
+
+func swap_nl(r rune) rune {
+	switch r {
+	case '\n':
+		return 0x85
+	case 0x85:
+		return '\n'
+	}
+
+	return r
+}
+
+func (self *swap_lfnl) DecodeRune(p []byte) (rune, int) {
+	r, n := self.codec.DecodeRune(p)
+	return swap_nl(r), n
+}
+
+func (self *swap_lfnl) FullRune(p []byte) bool {
+	return self.codec.FullRune(p)
+}
+
+func (self *swap_lfnl) EncodeRune(p []byte, r rune) int {
+	return self.codec.EncodeRune(p, swap_nl(r))
+}
+
+func (self *swap_lfnl) Reset() {
+	reset_decoder(self.codec)
+	reset_encoder(self.codec)
+}
+
+func (self *swap_lfnl) Flush(p []byte) int {
+	return flush(self.codec, p)
+}
+
+func (self *swap_lfnl) save_state() interface{} {
+	return save_encoder(self.codec)
+}
+
+func (self *swap_lfnl) restore_state(state interface{}) {
+	restore_encoder(self.codec, state)
+}
@@ -0,0 +1,162 @@
+package charenc
+
+import (
+	"fmt"
+)
+
+// DecodeError is returned when input contains invalid or incomplete byte sequence.
+// Offset is position of the sequence in the input stream, Index is number of runes decoded before it.
+// Line and Column are counted from 1, Column is counted in runes.
+type DecodeError struct {
+	Encoding string // Name of encoding ("" if unknown)
+	Offset   int64
+	Index    int64
+	Line     int
+	Column   int
+	Bytes    []byte // Invalid sequence
+}
+
+func (self *DecodeError) Error() string {
+	return fmt.Sprintf("charenc: can not decode %s: invalid sequence % X at offset %d, rune %d (line %d, column %d)",
+		encoding_label(self.Encoding), self.Bytes, self.Offset, self.Index, self.Line, self.Column)
+}
+
+// EncodeError is returned when rune can not be represented in the output encoding.
+// Offset is position of the rune in the input stream for Reader and Writer and position in the output
+// for EncodeRunes and RuneWriter which take runes. Rune is NoRune if encoder can not finish the text.
+type EncodeError struct {
+	Encoding string // Name of encoding ("" if unknown)
+	Offset   int64
+	Index    int64
+	Line     int
+	Column   int
+	Rune     rune
+}
+
+func (self *EncodeError) Error() string {
+	if self.Rune == NoRune {
+		return fmt.Sprintf("charenc: can not finish %s text at offset %d", encoding_label(self.Encoding), self.Offset)
+	}
+
+	return fmt.Sprintf("charenc: can not encode %U to %s at offset %d, rune %d (line %d, column %d)",
+		self.Rune, encoding_label(self.Encoding), self.Offset, self.Index, self.Line, self.Column)
+}
+
+func encoding_label(name string) string {
+	if name == "" {
+		return "unknown encoding"
+	}
+
+	return name
+}
+
+// Name of encoding implemented by codec ("" if unknown)
+func encoding_name(codec interface{}) string {
+	switch c := codec.(type) {
+	case enc_UTF8:
+		return "UTF-8"
+	case enc_UCS2LE:
+		return "UCS-2LE"
+	case enc_UCS2BE:
+		return "UCS-2BE"
+	case enc_UCS4LE:
+		return "UCS-4LE"
+	case enc_UCS4BE:
+		return "UCS-4BE"
+	case enc_UTF16LE:
+		return "UTF-16LE"
+	case enc_UTF16BE:
+		return "UTF-16BE"
+	case *enc_BOM:
+		return c.name
+	case bit8:
+		return names[c.id].name
+	case mbcs:
+		return mbnames[c.id].charset
+	case *mbcs_comb:
+		return mbnames[c.id].charset
+	case enc_GB18030:
+		return mbnames[c.id].charset
+	case *enc_ISO2022:
+		return c.variant.name
+	case *enc_UTF7:
+		return c.variant.name
+	case *enc_CESU8:
+		return c.variant.name
+	case enc_UTF_EBCDIC:
+		return "UTF-EBCDIC"
+	case *swap_lfnl:
+		return encoding_name(c.codec)
+	case *translit_encoder:
+		return encoding_name(c.encoder)
+	case *ignore_decoder:
+		return encoding_name(c.decoder)
+	case *ignore_encoder:
+		return encoding_name(c.encoder)
+	case *Charmap:
+		return c.name
+	case whatwg_sbcs:
+		return c.tbl.name
+	case whatwg_utf8:
+		return "UTF-8"
+	case *whatwg_replacement:
+		return "replacement"
+	case whatwg_user_defined:
+		return "x-user-defined"
+	case whatwg_gb18030:
+		if c.gbk {
+			return "GBK"
+		}
+		return "gb18030"
+	case *whatwg_big5:
+		return "Big5"
+	case whatwg_euc_jp:
+		return "EUC-JP"
+	case whatwg_shift_jis:
+		return "Shift_JIS"
+	case whatwg_euc_kr:
+		return "EUC-KR"
+	case *whatwg_iso2022_jp:
+		return "ISO-2022-JP"
+	case *whatwg_decoder:
+		return encoding_name(c.current)
+	}
+
+	return ""
+}
+
+// Position in the text: offset in bytes, number of runes, line and column
+type position struct {
+	offset int64
+	index  int64
+	line   int
+	column int
+}
+
+func (self *position) reset() {
+	*self = position{0, 0, 1, 1}
+}
+
+// Move position after rune r (NoRune if nothing was produced) represented by n bytes
+func (self *position) advance(r rune, n int) {
+	self.offset += int64(n)
+	if r == NoRune {
+		return
+	}
+
+	self.index++
+	if r == '\n' {
+		self.line++
+		self.column = 1
+	} else {
+		self.column++
+	}
+}
+
+func (self *position) decode_error(decoder RuneDecoder, seq []byte) error {
+	return &DecodeError{encoding_name(decoder), self.offset, self.index, self.line, self.column, append([]byte(nil), seq...)}
+}
+
+func (self *position) encode_error(encoder RuneEncoder, r rune) error {
+	return &EncodeError{encoding_name(encoder), self.offset, self.index, self.line, self.column, r}
+}
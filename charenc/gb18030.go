@@ -0,0 +1,123 @@
+package charenc
+
+// GB18030 encoder/decoder. One and two bytes codes are the same as GBK ones and stored in multibyte tables.
+// Four bytes codes are b1 b2 b3 b4 where b1, b3 are in 0x81..0xFE and b2, b4 are in 0x30..0x39.
+// Linear index of four bytes code is mapped to BMP characters using gb18030_ranges and to
+// supplementary planes by simple offset.
+type enc_GB18030 struct {
+	mbcs
+}
+
+const (
+	gb18030_bmp_size = 39420          // Number of four bytes codes mapped to BMP
+	gb18030_supp     = 189000         // Index of first code mapped to supplementary planes (0x90308130)
+	gb18030_max      = 189000 + 0x100000 // Index after last supplementary code (0xE3329A36)
+)
+
+// Check if p starts from four bytes sequence:
+func gb18030_four(p []byte) bool {
+	return len(p) >= 2 && p[0] >= 0x81 && p[0] <= 0xfe && p[1] >= 0x30 && p[1] <= 0x39
+}
+
+// Convert linear index of four bytes code to rune:
+func gb18030_to_rune(idx int) (rune, bool) {
+	if idx >= gb18030_supp && idx < gb18030_max {
+		return rune(idx - gb18030_supp + 0x10000), true
+	}
+
+	if idx < 0 || idx >= gb18030_bmp_size {
+		return 0, false
+	}
+
+	a, b := 0, len(gb18030_ranges)
+	for b - a > 1 {
+		c := (a + b) / 2
+		if int(gb18030_ranges[c].code) <= idx {
+			a = c
+		} else {
+			b = c
+		}
+	}
+
+	return gb18030_ranges[a].uchr + rune(idx - int(gb18030_ranges[a].code)), true
+}
+
+// Convert rune to linear index of four bytes code:
+func gb18030_from_rune(r rune) (int, bool) {
+	if r >= 0x10000 && r <= 0x10ffff {
+		return int(r - 0x10000) + gb18030_supp, true
+	}
+
+	if r < gb18030_ranges[0].uchr || r > 0xffff {
+		return 0, false
+	}
+
+	a, b := 0, len(gb18030_ranges)
+	for b - a > 1 {
+		c := (a + b) / 2
+		if gb18030_ranges[c].uchr <= r {
+			a = c
+		} else {
+			b = c
+		}
+	}
+
+	idx := int(gb18030_ranges[a].code) + int(r - gb18030_ranges[a].uchr)
+	if a + 1 < len(gb18030_ranges) && idx >= int(gb18030_ranges[a + 1].code) {
+		return 0, false // Character is in two bytes table
+	}
+	if idx >= gb18030_bmp_size {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+func (self enc_GB18030) DecodeRune(p []byte) (rune, int) {
+	if !gb18030_four(p) {
+		return self.mbcs.DecodeRune(p)
+	}
+
+	if len(p) < 4 {
+		return RuneError, 0
+	}
+	if p[2] < 0x81 || p[2] > 0xfe || p[3] < 0x30 || p[3] > 0x39 {
+		return RuneError, invalid_seq(p, 4)
+	}
+
+	idx := (((int(p[0]) - 0x81) * 10 + int(p[1]) - 0x30) * 126 + int(p[2]) - 0x81) * 10 + int(p[3]) - 0x30
+	r, ok := gb18030_to_rune(idx)
+	if !ok {
+		return RuneError, -4
+	}
+
+	return r, 4
+}
+
+func (self enc_GB18030) FullRune(p []byte) bool {
+	if gb18030_four(p) {
+		return len(p) >= 4
+	}
+
+	return self.mbcs.FullRune(p)
+}
+
+func (self enc_GB18030) EncodeRune(p []byte, r rune) int {
+	if l := self.mbcs.EncodeRune(p, r); l > 0 {
+		return l
+	}
+
+	idx, ok := gb18030_from_rune(r)
+	if !ok || len(p) < 4 {
+		return -1
+	}
+
+	p[3] = byte(0x30 + idx % 10)
+	idx /= 10
+	p[2] = byte(0x81 + idx % 126)
+	idx /= 126
+	p[1] = byte(0x30 + idx % 10)
+	p[0] = byte(0x81 + idx / 10)
+
+	return 4
+}
@@ -0,0 +1,122 @@
+package charenc
+
+import (
+	"fmt"
+)
+
+// ErrorHandler is called by readers and writers when conversion fails. err is *DecodeError for invalid
+// or incomplete input sequence and *EncodeError for rune which can not be represented in the output encoding.
+// Handler returns text which is used instead of the sequence or rune (it is encoded for EncodeError),
+// raw bytes written to the output as is (EncodeError only) or error to stop conversion.
+// nil handler is the same as StrictErrors.
+type ErrorHandler func(err error) (text []rune, raw []byte, fail error)
+
+// StrictErrors stops conversion and returns the error
+var StrictErrors ErrorHandler = strict_errors
+
+// IgnoreErrors skips invalid sequences and runes which can not be encoded
+var IgnoreErrors ErrorHandler = ignore_errors
+
+// ReplaceErrors replaces invalid sequences and runes which can not be encoded by '?'
+var ReplaceErrors ErrorHandler = ReplaceWith('?')
+
+// BackslashErrors replaces invalid bytes by \xNN and runes which can not be encoded by \xNN, \uNNNN or \UNNNNNNNN
+var BackslashErrors ErrorHandler = backslash_errors
+
+// SurrogateEscape decodes invalid byte b (0x80..0xFF) as lone surrogate U+DC00+b and encodes such
+// surrogates back to the original bytes, so any input can be converted and restored without losses.
+var SurrogateEscape ErrorHandler = surrogate_escape
+
+func strict_errors(err error) ([]rune, []byte, error) {
+	return nil, nil, err
+}
+
+func ignore_errors(err error) ([]rune, []byte, error) {
+	return nil, nil, nil
+}
+
+// ReplaceWith returns handler which replaces invalid sequences and runes which can not be encoded by r
+// (RuneError or '?' for example). Conversion fails if r itself can not be encoded.
+func ReplaceWith(r rune) ErrorHandler {
+	return func(err error) ([]rune, []byte, error) {
+		return []rune{r}, nil, nil
+	}
+}
+
+func backslash_errors(err error) ([]rune, []byte, error) {
+	var s string
+	switch e := err.(type) {
+	case *DecodeError:
+		for _, b := range(e.Bytes) {
+			s += fmt.Sprintf("\\x%02x", b)
+		}
+	case *EncodeError:
+		if e.Rune < 0 {
+			return nil, nil, err
+		} else if e.Rune < 0x100 {
+			s = fmt.Sprintf("\\x%02x", e.Rune)
+		} else if e.Rune < 0x10000 {
+			s = fmt.Sprintf("\\u%04x", e.Rune)
+		} else {
+			s = fmt.Sprintf("\\U%08x", e.Rune)
+		}
+	default:
+		return nil, nil, err
+	}
+
+	return []rune(s), nil, nil
+}
+
+func surrogate_escape(err error) ([]rune, []byte, error) {
+	switch e := err.(type) {
+	case *DecodeError:
+		res := make([]rune, len(e.Bytes))
+		for i, b := range(e.Bytes) {
+			if b < 0x80 { // ASCII bytes are never escaped
+				return nil, nil, err
+			}
+			res[i] = 0xdc00 + rune(b)
+		}
+		return res, nil, nil
+	case *EncodeError:
+		if e.Rune >= 0xdc80 && e.Rune <= 0xdcff {
+			return nil, []byte{byte(e.Rune - 0xdc00)}, nil
+		}
+	}
+
+	return nil, nil, err
+}
+
+// Call error handler (nil handler is strict)
+func handle_error(handler ErrorHandler, err error) ([]rune, []byte, error) {
+	if handler == nil {
+		return nil, nil, err
+	}
+
+	return handler(err)
+}
+
+// Append encoded rune to dst calling error handler if encoder fails
+func encode_handled(dst []byte, encoder RuneEncoder, r rune, handler ErrorHandler, at *position) ([]byte, error) {
+	var buf [32]byte
+	if l := encoder.EncodeRune(buf[:], r); l >= 0 {
+		return append(dst, buf[:l]...), nil
+	}
+
+	err := at.encode_error(encoder, r)
+	text, raw, e := handle_error(handler, err)
+	if e != nil {
+		return dst, e
+	}
+
+	dst = append(dst, raw...)
+	for _, c := range(text) {
+		l := encoder.EncodeRune(buf[:], c)
+		if l < 0 {
+			return dst, err
+		}
+		dst = append(dst, buf[:l]...)
+	}
+
+	return dst, nil
+}
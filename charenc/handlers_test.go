@@ -0,0 +1,47 @@
+package charenc
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// Conversion of invalid UTF-8 byte and "é" which is not in ASCII
+var handler_tests = []struct {
+	name    string
+	handler ErrorHandler
+	to      string
+	out     string
+	err     bool
+}{
+	{"strict", StrictErrors, "ASCII", "a", true},
+	{"nil", nil, "ASCII", "a", true},
+	{"ignore", IgnoreErrors, "ASCII", "ab", false},
+	{"replace", ReplaceErrors, "ASCII", "a?b?", false},
+	{"replace with U+FFFD", ReplaceWith(RuneError), "UTF-8", "a�bé", false},
+	{"backslash", BackslashErrors, "ASCII", "a\\xffb\\xe9", false},
+	{"surrogateescape", SurrogateEscape, "UTF-8", "a\xffbé", false},
+	{"surrogateescape", SurrogateEscape, "ASCII", "a\xffb", true},
+}
+
+func TestErrorHandlers(t *testing.T) {
+	for _, x := range(handler_tests) {
+		r := GetReader(strings.NewReader("a\xffbé"), "UTF-8", x.to, x.handler)
+		out, err := ioutil.ReadAll(r)
+		if string(out) != x.out || (err != nil) != x.err {
+			t.Errorf("%s to %s: got %q (%v), want %q", x.name, x.to, out, err, x.out)
+		}
+	}
+
+	// Escaped bytes are restored by encoder
+	text, _, _ := SurrogateEscape(&DecodeError{Bytes: []byte{0x80, 0xff}})
+	if string(text) != string([]rune{0xdc80, 0xdcff}) {
+		t.Errorf("surrogateescape: decode 80 FF: got %U", text)
+	}
+	if _, raw, err := SurrogateEscape(&EncodeError{Rune: 0xdcff}); err != nil || string(raw) != "\xff" {
+		t.Errorf("surrogateescape: encode U+DCFF: got %q (%v)", raw, err)
+	}
+	if _, _, err := SurrogateEscape(&DecodeError{Bytes: []byte{'a'}}); err == nil {
+		t.Errorf("surrogateescape: ASCII byte is escaped")
+	}
+}
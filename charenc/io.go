@@ -3,7 +3,6 @@ package charenc
 
 import (
 	"io"
-	"errors"
 )
 
 type RuneReader struct {
@@ -11,11 +10,13 @@ type RuneReader struct {
 	decoder RuneDecoder
 	buf []byte
 	pos, cnt int
-	erract  int
+	handler ErrorHandler
 	err error
+	tail []rune // Replacement runes which didn't fit into the output buffer
+	at position // Position of the next character in the input
 }
 
-func NewRuneReader(reader io.Reader, decoder RuneDecoder, erract int) *RuneReader {
+func NewRuneReader(reader io.Reader, decoder RuneDecoder, handler ErrorHandler) *RuneReader {
 	res := new(RuneReader)
 
 	res.decoder = decoder
@@ -24,12 +25,32 @@ func NewRuneReader(reader io.Reader, decoder RuneDecoder, erract int) *RuneReade
 	res.cnt = 0
 	res.buf = make([]byte, 256)
 	res.err = nil
-	res.erract = erract
+	res.handler = handler
+	res.at.reset()
+	reset_decoder(decoder)
 
 	return res
 }
 
+// Reset makes RuneReader read runes from the new stream. State of stateful decoder is reset too.
+func (self *RuneReader) Reset(reader io.Reader) {
+	self.reader = reader
+	self.pos = 0
+	self.cnt = 0
+	self.err = nil
+	self.tail = nil
+	self.at.reset()
+	reset_decoder(self.decoder)
+}
+
+// ReadRunes reads runes into p. Invalid sequence is passed to error handler as *DecodeError.
 func (self *RuneReader) ReadRunes(p []rune) (int, error) {
+	if len(self.tail) > 0 {
+		n := copy(p, self.tail)
+		self.tail = self.tail[n:]
+		return n, nil
+	}
+
 	// Read from input if we don't have enought bytes:
 	if self.err == nil && self.cnt - self.pos < 8 {
 		if self.pos > 0 {
@@ -53,28 +74,35 @@ func (self *RuneReader) ReadRunes(p []rune) (int, error) {
 	// Write output:
 	var pos int = 0
 	for pos = 0; pos < len(p); {
-		if !self.decoder.FullRune(self.buf[self.pos:]) {
+		var r rune
+		var cnt int
+		if self.decoder.FullRune(self.buf[self.pos:self.cnt]) {
+			r, cnt = self.decoder.DecodeRune(self.buf[self.pos:self.cnt])
+		} else if self.err == nil || self.pos == self.cnt {
 			return pos, self.err // self.err may be EOF and it is Ok for us
+		} else { // Incomplete sequence at the end of stream
+			r, cnt = decode_last(self.decoder, self.buf[self.pos:self.cnt])
 		}
-
-		r, cnt := self.decoder.DecodeRune(self.buf[self.pos:])
 		if cnt < 0 {
-			if self.erract == ReplaceErrors {
-				p[pos] = '?'
-				pos++
-				self.pos++
-			} else if self.erract == IgnoreErrors {
-				r = 0
-				self.pos++
-			} else {
-				self.err = errors.New("Unicode decoder failed")
-				return pos, self.err
+			text, _, e := handle_error(self.handler, self.at.decode_error(self.decoder, self.buf[self.pos:self.pos - cnt]))
+			if e != nil {
+				self.err = e
+				return pos, e
 			}
-		} else {
+			n := copy(p[pos:], text)
+			self.tail = text[n:]
+			pos += n
+			self.at.advance(RuneError, -cnt)
+			self.pos -= cnt
+			continue
+		}
+
+		if r != NoRune {
 			p[pos] = r
 			pos++
-			self.pos += cnt
 		}
+		self.at.advance(r, cnt)
+		self.pos += cnt
 	}
 
 	return pos, nil
@@ -87,11 +115,14 @@ type Reader struct {
 	encoder RuneEncoder
 	buf []byte
 	pos, cnt int
-	erract  int
+	handler ErrorHandler
 	err error
+	tail []byte // Encoded bytes which didn't fit into the output buffer
+	flushed bool // Final bytes of encoder have written
+	at position // Position of the next character in the input
 }
 
-func NewReader(reader io.Reader, decoder RuneDecoder, encoder RuneEncoder, erract int) *Reader {
+func NewReader(reader io.Reader, decoder RuneDecoder, encoder RuneEncoder, handler ErrorHandler) *Reader {
 	res := new(Reader)
 
 	res.decoder = decoder
@@ -101,12 +132,15 @@ func NewReader(reader io.Reader, decoder RuneDecoder, encoder RuneEncoder, errac
 	res.cnt = 0
 	res.buf = make([]byte, 256)
 	res.err = nil
-	res.erract = erract
+	res.handler = handler
+	res.at.reset()
+	reset_decoder(decoder)
+	reset_encoder(encoder)
 
 	return res
 }
 
-func GetReader(reader io.Reader, from_charset, to_charset string, erract int) *Reader {
+func GetReader(reader io.Reader, from_charset, to_charset string, handler ErrorHandler) *Reader {
 	decoder := NewRuneDecoder(from_charset)
 	encoder := NewRuneEncoder(to_charset)
 
@@ -114,11 +148,33 @@ func GetReader(reader io.Reader, from_charset, to_charset string, erract int) *R
 		return nil
 	}
 
-	return NewReader(reader, decoder, encoder, erract)
+	return NewReader(reader, decoder, encoder, handler)
+}
+
+// Reset makes Reader convert the new stream. States of stateful decoder and encoder are reset too.
+func (self *Reader) Reset(reader io.Reader) {
+	self.reader = reader
+	self.pos = 0
+	self.cnt = 0
+	self.err = nil
+	self.tail = nil
+	self.flushed = false
+	self.at.reset()
+	reset_decoder(self.decoder)
+	reset_encoder(self.encoder)
 }
 
+// Read reads converted bytes into p. Conversion errors are passed to error handler as *DecodeError or *EncodeError.
 func (self *Reader) Read(p []byte) (int, error) {
 	var charbuf []byte = make([]byte, 8)
+	var e error
+
+	if len(self.tail) > 0 {
+		n := copy(p, self.tail)
+		self.tail = self.tail[n:]
+		return n, nil
+	}
+
 	// Read from input if we don't have enought bytes:
 	if self.err == nil && self.cnt - self.pos < len(charbuf) {
 		if self.pos > 0 {
@@ -135,6 +191,19 @@ func (self *Reader) Read(p []byte) (int, error) {
 		self.cnt += n
 	}
 
+	// Stateful encoders must return to initial state at the end of text:
+	if self.cnt == 0 && self.err == io.EOF && !self.flushed {
+		self.flushed = true
+		l := flush(self.encoder, charbuf)
+		if l < 0 {
+			self.err = self.at.encode_error(self.encoder, NoRune)
+			return 0, self.err
+		}
+		n := copy(p, charbuf[:l])
+		self.tail = charbuf[n:l]
+		return n, nil
+	}
+
 	if self.cnt == 0 { // This if is only for optimization: actually we can remove this lines
 		return 0, self.err
 	}
@@ -142,100 +211,114 @@ func (self *Reader) Read(p []byte) (int, error) {
 	// Write output:
 	var pos int = 0
 	for pos = 0; pos < len(p); {
-		if !self.decoder.FullRune(self.buf[self.pos:]) {
-			return pos, self.err // self.err may be EOF and it is Ok for us
+		var r rune
+		var cnt int
+		if self.decoder.FullRune(self.buf[self.pos:self.cnt]) {
+			r, cnt = self.decoder.DecodeRune(self.buf[self.pos:self.cnt])
+		} else if self.err == nil || self.pos == self.cnt {
+			return pos, self.status() // self.err may be EOF and it is Ok for us
+		} else { // Incomplete sequence at the end of stream
+			r, cnt = decode_last(self.decoder, self.buf[self.pos:self.cnt])
 		}
-
-		r, cnt := self.decoder.DecodeRune(self.buf[self.pos:])
+		charbuf = charbuf[:0]
 		if cnt < 0 {
-			if self.erract == ReplaceErrors {
-				r = '?'
-				cnt = 1
-			} else if self.erract == IgnoreErrors {
-				r = 0
-			} else {
-				self.err = errors.New("Unicode decoder failed")
-				return pos, self.err
+			var text []rune
+			text, _, e = handle_error(self.handler, self.at.decode_error(self.decoder, self.buf[self.pos:self.pos - cnt]))
+			for i := 0; e == nil && i < len(text); i++ {
+				charbuf, e = encode_handled(charbuf, self.encoder, text[i], self.handler, &self.at)
 			}
+			r, cnt = RuneError, -cnt
+		} else if r != NoRune {
+			charbuf, e = encode_handled(charbuf, self.encoder, r, self.handler, &self.at)
 		}
-
-		var ocnt int = 0
-		if cnt >= 0 {
-			ocnt = self.encoder.EncodeRune(charbuf, r)
-			if ocnt < 0 {
-				self.err = errors.New("Unicode encoder failed");
-				return pos, self.err
-			}
-			if ocnt + pos >= len(p) { // Buffer is full
-				return pos, nil
-			}
-			// Copy charbuf to buf:
-			copy(p[pos:], charbuf[:ocnt])
-		}
-		if cnt < 0 {
-			cnt = 1
+		if e != nil {
+			self.err = e
+			return pos, e
 		}
 
-		pos += ocnt
+		// Copy charbuf to buf, the rest will be returned by the next call:
+		n := copy(p[pos:], charbuf)
+		self.tail = charbuf[n:]
+		pos += n
+
+		self.at.advance(r, cnt)
 		self.pos += cnt
 		if self.pos >= self.cnt {
 			self.pos = 0
 			self.cnt = 0
-			return pos, self.err // At this point input buffer empty so we can return error (EOF for example)
+			return pos, self.status() // At this point input buffer empty so we can return error (EOF for example)
 		}
 	}
 
 	return pos, nil
 }
 
+// Error to return with data. EOF is returned only after final bytes of encoder.
+func (self *Reader) status() error {
+	if self.err == io.EOF && !self.flushed {
+		return nil
+	}
+
+	return self.err
+}
+
 type RuneWriter struct {
 	writer io.Writer
 	encoder RuneEncoder
 	err error
-	erract  int
+	handler ErrorHandler
+	at position // Position of the next character in the output
 }
 
-func NewRuneWriter(writer io.Writer, encoder RuneEncoder, erract int) *RuneWriter {
+func NewRuneWriter(writer io.Writer, encoder RuneEncoder, handler ErrorHandler) *RuneWriter {
 	r := new(RuneWriter)
 	r.writer = writer
 	r.encoder = encoder
 	r.err = nil
-	r.erract = erract
+	r.handler = handler
+	r.at.reset()
+	reset_encoder(encoder)
 
 	return r
 }
 
-func (self RuneWriter) WriteRunes(p []rune) (int, error) {
-	buf := make([]byte, 256)
-	pos := 0
+// Reset makes RuneWriter write to the new stream. State of stateful encoder is reset too.
+// Call Flush before Reset to finish the previous stream.
+func (self *RuneWriter) Reset(writer io.Writer) {
+	self.writer = writer
+	self.err = nil
+	self.at.reset()
+	reset_encoder(self.encoder)
+}
+
+// WriteRunes encodes runes and writes them. Rune which can not be encoded is passed to error handler as *EncodeError.
+func (self *RuneWriter) WriteRunes(p []rune) (int, error) {
+	buf := make([]byte, 0, 256)
 	var e error
 	l := 0
 	for i := range(p) {
-		if pos + 8 > len(buf) {
-			l, e = self.writer.Write(buf[:pos])
-			if l < pos {
+		if len(buf) + 8 > cap(buf) {
+			l, e = self.writer.Write(buf)
+			if l < len(buf) {
 				return i, e
 			}
-			pos = 0
+			buf = buf[:0]
 		}
 
-		l = self.encoder.EncodeRune(buf[pos:], p[i])
-		if l < 0 {
-			if self.erract == ReplaceErrors {
-				buf[pos] = '?'
-				pos++
-			} else if self.erract == IgnoreErrors {
-			} else {
-				return i, errors.New("Can not encode character")
+		n := len(buf)
+		buf, e = encode_handled(buf, self.encoder, p[i], self.handler, &self.at)
+		if e != nil {
+			if len(buf) > 0 {
+				self.writer.Write(buf)
 			}
-		} else {
-			pos += l
+			return i, e
 		}
+		self.at.advance(p[i], len(buf) - n)
 	}
 
-	if pos > 0 {
-		l, e = self.writer.Write(buf[:pos])
-		if l < pos {
+	if len(buf) > 0 {
+		l, e = self.writer.Write(buf)
+		if l < len(buf) {
 			return len(p), e // It is not true but we can not determine how many characters had written
 		}
 	}
@@ -243,28 +326,192 @@ func (self RuneWriter) WriteRunes(p []rune) (int, error) {
 	return len(p), nil
 }
 
+// Flush writes pending bytes of stateful encoder (return to ASCII or buffered character for example).
+// Call it at the end of text.
+func (self *RuneWriter) Flush() error {
+	buf := make([]byte, 8)
+	l := flush(self.encoder, buf)
+	if l < 0 {
+		return self.at.encode_error(self.encoder, NoRune)
+	}
+
+	if l > 0 {
+		_, e := self.writer.Write(buf[:l])
+		return e
+	}
+
+	return nil
+}
+
+// Writer takes bytes in first encoding and writes them to io.Writer in second one.
+// Incomplete sequences are kept until the next Write call. Call Close at the end of text.
 type Writer struct {
-	writer *RuneWriter
-	buf []byte
-	pos   int
+	writer  io.Writer
+	decoder RuneDecoder
+	encoder RuneEncoder
+	buf []byte // Incomplete sequence from the previous Write call
+	handler ErrorHandler
+	err error
+	at position // Position of the next character in the input
 }
 
-func NewWriter(writer io.Writer, encoder RuneEncoder, decoder RuneDecoder) *Writer {
+func NewWriter(writer io.Writer, decoder RuneDecoder, encoder RuneEncoder, handler ErrorHandler) *Writer {
 	res := new(Writer)
-	res.buf = nil
+
 	res.writer = writer
-	res.encoder = encoder
 	res.decoder = decoder
+	res.encoder = encoder
+	res.buf = nil
+	res.handler = handler
 	res.err = nil
+	res.at.reset()
+	reset_decoder(decoder)
+	reset_encoder(encoder)
 
 	return res
 }
 
+func GetWriter(writer io.Writer, from_charset, to_charset string, handler ErrorHandler) *Writer {
+	decoder := NewRuneDecoder(from_charset)
+	encoder := NewRuneEncoder(to_charset)
+
+	if decoder == nil || encoder == nil {
+		return nil
+	}
+
+	return NewWriter(writer, decoder, encoder, handler)
+}
+
+// Reset makes Writer write to the new stream. States of stateful decoder and encoder are reset too.
+// Call Close before Reset to finish the previous stream.
+func (self *Writer) Reset(writer io.Writer) {
+	self.writer = writer
+	self.buf = nil
+	self.err = nil
+	self.at.reset()
+	reset_decoder(self.decoder)
+	reset_encoder(self.encoder)
+}
+
+// Write converts p and writes result. Incomplete sequence at the end of p is kept for the next call.
+// Conversion errors are passed to error handler as *DecodeError or *EncodeError.
 func (self *Writer) Write(p []byte) (int, error) {
+	if self.err != nil {
+		return 0, self.err
+	}
+
+	prev := len(self.buf)
+	in := append(self.buf, p...)
+	out := make([]byte, 0, len(in) + 8)
+	var ends [][2]int // Input position after character and length of output, to count input written before write error
+
+	pos := 0
+	for pos < len(in) && self.decoder.FullRune(in[pos:]) {
+		r, cnt := self.decoder.DecodeRune(in[pos:])
+		if cnt == 0 && r == RuneError {
+			cnt = -1
+		}
+		if cnt < 0 {
+			out, self.err = self.replace(out, in[pos:pos - cnt])
+			r, cnt = RuneError, -cnt
+		} else if r != NoRune {
+			out, self.err = encode_handled(out, self.encoder, r, self.handler, &self.at)
+		}
+		if self.err != nil {
+			break
+		}
+		self.at.advance(r, cnt)
+		pos += cnt
+		ends = append(ends, [2]int{pos, len(out)})
+	}
+
+	// Keep incomplete sequence for the next call:
+	self.buf = append([]byte(nil), in[pos:]...)
+	if self.err != nil {
+		self.buf = nil
+	}
+
+	n := pos - prev
+	if n < 0 {
+		n = 0
+	}
+	if self.err == nil {
+		n = len(p)
+	}
 
-	return 0, io.EOF
+	if len(out) > 0 {
+		l, e := self.writer.Write(out)
+		if e == nil && l < len(out) {
+			e = io.ErrShortWrite
+		}
+		if e != nil {
+			self.err = e
+			written := 0
+			for i := 0; i < len(ends) && ends[i][1] <= l; i++ {
+				written = ends[i][0]
+			}
+			if written -= prev; written < 0 {
+				written = 0
+			}
+			return written, e
+		}
+	}
+
+	return n, self.err
 }
 
+// Append replacement of invalid sequence to out
+func (self *Writer) replace(out []byte, seq []byte) ([]byte, error) {
+	text, _, e := handle_error(self.handler, self.at.decode_error(self.decoder, seq))
+	for i := 0; e == nil && i < len(text); i++ {
+		out, e = encode_handled(out, self.encoder, text[i], self.handler, &self.at)
+	}
+
+	return out, e
+}
 
+// Close passes incomplete sequence to error handler and writes final bytes of stateful encoder.
+// Underlying writer is not closed.
+func (self *Writer) Close() error {
+	if self.err != nil {
+		return self.err
+	}
 
+	out := make([]byte, 0, 16)
+	charbuf := make([]byte, 8)
 
+	for len(self.buf) > 0 { // Incomplete sequence at the end of stream
+		r, cnt := decode_last(self.decoder, self.buf)
+		if cnt < 0 {
+			out, self.err = self.replace(out, self.buf[:-cnt])
+			r, cnt = RuneError, -cnt
+		} else if r != NoRune {
+			out, self.err = encode_handled(out, self.encoder, r, self.handler, &self.at)
+		}
+		if self.err != nil {
+			return self.err
+		}
+		self.at.advance(r, cnt)
+		self.buf = self.buf[cnt:]
+	}
+
+	l := flush(self.encoder, charbuf)
+	if l < 0 {
+		self.err = self.at.encode_error(self.encoder, NoRune)
+		return self.err
+	}
+	out = append(out, charbuf[:l]...)
+
+	if len(out) > 0 {
+		n, e := self.writer.Write(out)
+		if e == nil && n < len(out) {
+			e = io.ErrShortWrite
+		}
+		if e != nil {
+			self.err = e
+			return e
+		}
+	}
+
+	return nil
+}
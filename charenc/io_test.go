@@ -0,0 +1,86 @@
+package charenc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Input and output are split to single bytes, so incomplete sequences and pending output are kept between calls
+var stream_tests = []struct {
+	from, to string
+	in, out  string
+}{
+	{"UTF-8", "ISO-2022-JP", "日本語 abc", "\x1b$BF|K\\8l\x1b(B abc"},
+	{"UTF-8", "ISO-2022-JP", "abc 日本語", "abc \x1b$BF|K\\8l\x1b(B"},
+	{"ISO-2022-JP", "UTF-8", "\x1b$BF|K\\8l\x1b(B abc", "日本語 abc"},
+	{"UTF-8", "UTF-7", "日本語", "+ZeVnLIqe-"},
+	{"UTF-7", "UTF-16BE", "+2D3eAA-", "\xd8\x3d\xde\x00"},
+	{"GB18030", "UTF-8", "\x81\x30\x84\x36\x90\x30\x81\x30", "¥\U00010000"},
+}
+
+func TestReader(t *testing.T) {
+	for _, x := range(stream_tests) {
+		r := GetReader(iotest.OneByteReader(strings.NewReader(x.in)), x.from, x.to, nil)
+		out, err := ioutil.ReadAll(iotest.OneByteReader(r))
+		if err != nil || string(out) != x.out {
+			t.Errorf("%s to %s: read %q: got %q (%v), want %q", x.from, x.to, x.in, out, err, x.out)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for _, x := range(stream_tests) {
+		var buf bytes.Buffer
+		w := GetWriter(&buf, x.from, x.to, nil)
+		for i := 0; i < len(x.in); i++ {
+			if n, err := w.Write([]byte{x.in[i]}); n != 1 || err != nil {
+				t.Fatalf("%s to %s: write %q: got %d (%v)", x.from, x.to, x.in[i], n, err)
+			}
+		}
+		if err := w.Close(); err != nil || buf.String() != x.out {
+			t.Errorf("%s to %s: write %q: got %q (%v), want %q", x.from, x.to, x.in, buf.String(), err, x.out)
+		}
+	}
+}
+
+// Writer which accepts only limit bytes
+type limited_writer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+var errLimit = errors.New("limit is reached")
+
+func (self *limited_writer) Write(p []byte) (int, error) {
+	if len(p) > self.limit {
+		n, _ := self.buf.Write(p[:self.limit])
+		self.limit = 0
+		return n, errLimit
+	}
+
+	self.limit -= len(p)
+	return self.buf.Write(p)
+}
+
+func TestWriterShortWrite(t *testing.T) {
+	lw := &limited_writer{limit: 3}
+	w := GetWriter(lw, "UTF-8", "UTF-16BE", nil)
+
+	// Incomplete sequence is kept and counted as written
+	if n, err := w.Write([]byte("\xe6\x97")); n != 2 || err != nil {
+		t.Fatalf("incomplete sequence: got %d (%v)", n, err)
+	}
+
+	// U+65E5 completed by the first byte is written, "a" doesn't fit
+	n, err := w.Write([]byte("\xa5ab"))
+	if n != 1 || err != errLimit {
+		t.Errorf("short write: got %d (%v), want 1 (%v)", n, err, errLimit)
+	}
+	if n, err := w.Write([]byte("c")); n != 0 || err != errLimit {
+		t.Errorf("write after error: got %d (%v)", n, err)
+	}
+}
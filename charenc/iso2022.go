@@ -0,0 +1,654 @@
+package charenc
+
+// ISO-2022 encoders/decoders. Text in these encodings is 7-bit and character sets are
+// switched by escape sequences (ISO-2022-JP family) or by SO/SI after designation (ISO-2022-KR, ISO-2022-CN).
+// Both decoder and encoder keep current character sets between calls so they must be created for each stream.
+
+const (
+	esc = 0x1b
+	so  = 0x0e
+	si  = 0x0f
+)
+
+// Character sets:
+const (
+	cs_ascii = iota
+	cs_jis_roman     // JIS X 0201 Roman
+	cs_jis_kana      // JIS X 0201 Katakana
+	cs_jis0208_1978  // JIS C 6226-1978
+	cs_jis0208       // JIS X 0208-1983
+	cs_jis0212       // JIS X 0212-1990
+	cs_gb2312        // GB 2312-80
+	cs_ksc5601       // KS C 5601-1987
+	cs_jis0213_1     // JIS X 0213:2000 plane 1
+	cs_jis0213_2004  // JIS X 0213:2004 plane 1
+	cs_jis0213_2     // JIS X 0213 plane 2
+	cs_iso8859_1     // ISO-8859-1 upper half (G2)
+	cs_iso8859_7     // ISO-8859-7 upper half (G2)
+	cs_ksc5601_g1    // KS C 5601 in G1 (ISO-2022-KR)
+	cs_gb2312_g1     // GB 2312 in G1 (ISO-2022-CN)
+	cs_cns1_g1       // CNS 11643 plane 1 in G1 (ISO-2022-CN)
+	cs_cns2_g2       // CNS 11643 plane 2 in G2 (ISO-2022-CN)
+	cs_count
+)
+
+type iso2022_charset struct {
+	esc    string // Designation sequence
+	g      int    // Graphic set: 0 - G0, 1 - G1 (shifted by SO), 2 - G2 (single shift ESC N)
+	width  int    // Number of bytes per character
+	table  string // Name of multibyte (EUC form) or 8-bit table. Empty for ASCII and not supported sets.
+	prefix uint32 // Prefix of the code in multibyte table (0x8e for JIS X 0201 Katakana in EUC-JP for example)
+}
+
+// Set of 96 characters (designated by ESC - F, ESC . F or ESC / F) includes 0x20 and 0x7F
+func iso2022_set96(cs int) bool {
+	if cs < 0 {
+		return false
+	}
+
+	e := iso2022_charsets[cs].esc
+	return len(e) == 3 && (e[1] == '-' || e[1] == '.' || e[1] == '/')
+}
+
+var iso2022_charsets = [cs_count]iso2022_charset{
+	cs_ascii:        {"\x1b(B", 0, 1, "", 0},
+	cs_jis_roman:    {"\x1b(J", 0, 1, "", 0},
+	cs_jis_kana:     {"\x1b(I", 0, 1, "euc_jp", 0x8e},
+	cs_jis0208_1978: {"\x1b$@", 0, 2, "euc_jp", 0},
+	cs_jis0208:      {"\x1b$B", 0, 2, "euc_jp", 0},
+	cs_jis0212:      {"\x1b$(D", 0, 2, "euc_jp", 0x8f},
+	cs_gb2312:       {"\x1b$A", 0, 2, "gb2312", 0},
+	cs_ksc5601:      {"\x1b$(C", 0, 2, "euc_kr", 0},
+	cs_jis0213_1:    {"\x1b$(O", 0, 2, "euc_jisx0213", 0},
+	cs_jis0213_2004: {"\x1b$(Q", 0, 2, "euc_jis_2004", 0},
+	cs_jis0213_2:    {"\x1b$(P", 0, 2, "euc_jis_2004", 0x8f},
+	cs_iso8859_1:    {"\x1b.A", 2, 1, "iso8859_1", 0},
+	cs_iso8859_7:    {"\x1b.F", 2, 1, "iso8859_7", 0},
+	cs_ksc5601_g1:   {"\x1b$)C", 1, 2, "euc_kr", 0},
+	cs_gb2312_g1:    {"\x1b$)A", 1, 2, "gb2312", 0},
+	cs_cns1_g1:      {"\x1b$)G", 1, 2, "euc_tw", 0},
+	cs_cns2_g2:      {"\x1b$*H", 2, 2, "euc_tw", 0x8ea2},
+}
+
+type iso2022_variant struct {
+	decode []int // Character sets which can be designated in the input
+	encode []int // Character sets used by encoder in order of preference
+	header bool  // G1 designation is written once at the beginning of text (ISO-2022-KR)
+	name   string // Canonical name of encoding
+}
+
+var (
+	iso2022_jp = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis0208_1978, cs_jis0208},
+		[]int{cs_ascii, cs_jis0208, cs_jis_roman}, false, "iso2022_jp"}
+	iso2022_jp_1 = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis0208_1978, cs_jis0208, cs_jis0212},
+		[]int{cs_ascii, cs_jis0208, cs_jis0212, cs_jis_roman}, false, "iso2022_jp_1"}
+	iso2022_jp_2 = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis0208_1978, cs_jis0208, cs_jis0212, cs_gb2312, cs_ksc5601, cs_iso8859_1, cs_iso8859_7},
+		[]int{cs_ascii, cs_jis0208, cs_jis0212, cs_ksc5601, cs_gb2312, cs_jis_roman, cs_iso8859_1, cs_iso8859_7}, false, "iso2022_jp_2"}
+	iso2022_jp_3 = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis_kana, cs_jis0208_1978, cs_jis0208, cs_jis0213_1, cs_jis0213_2},
+		[]int{cs_ascii, cs_jis0208, cs_jis0213_1, cs_jis0213_2}, false, "iso2022_jp_3"}
+	iso2022_jp_2004 = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis_kana, cs_jis0208_1978, cs_jis0208, cs_jis0213_1, cs_jis0213_2004, cs_jis0213_2},
+		[]int{cs_ascii, cs_jis0208, cs_jis0213_2004, cs_jis0213_2}, false, "iso2022_jp_2004"}
+	iso2022_jp_ext = iso2022_variant{
+		[]int{cs_ascii, cs_jis_roman, cs_jis_kana, cs_jis0208_1978, cs_jis0208, cs_jis0212},
+		[]int{cs_ascii, cs_jis0208, cs_jis0212, cs_jis_roman, cs_jis_kana}, false, "iso2022_jp_ext"}
+	iso2022_kr = iso2022_variant{
+		[]int{cs_ascii, cs_ksc5601_g1},
+		[]int{cs_ascii, cs_ksc5601_g1}, true, "iso2022_kr"}
+	iso2022_cn = iso2022_variant{
+		[]int{cs_ascii, cs_gb2312_g1, cs_cns1_g1, cs_cns2_g2},
+		[]int{cs_ascii, cs_gb2312_g1, cs_cns1_g1, cs_cns2_g2}, false, "iso2022_cn"}
+)
+
+func iso2022_new(variant *iso2022_variant) func() CharacterEncoding {
+	return func() CharacterEncoding {
+		return open_iso2022(variant)
+	}
+}
+
+var iso2022_encodings = []Encoding{
+	{"iso2022_jp", []string{"ISO2022JP", "ISO_2022_JP", "CSISO2022JP"}, 39, "ISO-2022-JP", Stateful,
+		"ISO-2022-JP Japanese (RFC 1468)", iso2022_new(&iso2022_jp)},
+	{"iso2022_jp_1", []string{"ISO2022JP_1", "ISO_2022_JP_1"}, 0, "", Stateful,
+		"ISO-2022-JP-1 Japanese with JIS X 0212 (RFC 2237)", iso2022_new(&iso2022_jp_1)},
+	{"iso2022_jp_2", []string{"ISO2022JP_2", "ISO_2022_JP_2", "CSISO2022JP2"}, 40, "", Stateful,
+		"ISO-2022-JP-2 multilingual (RFC 1554)", iso2022_new(&iso2022_jp_2)},
+	{"iso2022_jp_3", []string{"ISO2022JP_3", "ISO_2022_JP_3"}, 0, "", Stateful,
+		"ISO-2022-JP-3 Japanese with JIS X 0213:2000", iso2022_new(&iso2022_jp_3)},
+	{"iso2022_jp_2004", []string{"ISO2022JP_2004", "ISO_2022_JP_2004"}, 0, "", Stateful,
+		"ISO-2022-JP-2004 Japanese with JIS X 0213:2004", iso2022_new(&iso2022_jp_2004)},
+	{"iso2022_jp_ext", []string{"ISO2022JP_EXT", "ISO_2022_JP_EXT"}, 0, "", Stateful,
+		"ISO-2022-JP with JIS X 0212 and half width katakana", iso2022_new(&iso2022_jp_ext)},
+	{"iso2022_kr", []string{"ISO2022KR", "ISO_2022_KR", "CSISO2022KR"}, 37, "", Stateful,
+		"ISO-2022-KR Korean (RFC 1557)", iso2022_new(&iso2022_kr)},
+	{"iso2022_cn", []string{"ISO2022CN", "ISO_2022_CN", "CSISO2022CN"}, 104, "", Stateful,
+		"ISO-2022-CN Chinese (RFC 1922)", iso2022_new(&iso2022_cn)},
+}
+
+type enc_ISO2022 struct {
+	variant *iso2022_variant
+	tables  [cs_count]int // Numbers of multibyte or 8-bit tables for character sets
+
+	// Decoder state:
+	g      [3]int // Character sets designated to G0, G1, G2 (-1 if not designated)
+	shift  bool   // SO is active
+	second rune   // Second character of pair (JIS X 0213)
+
+	// Encoder state:
+	out     [3]int // Character sets designated to G0, G1, G2 in the output
+	oshift  bool   // SO has written
+	started bool   // Header has written
+	pending rune   // Character which can be the first one of pair (JIS X 0213) waiting for the next one or 0
+}
+
+func open_iso2022(variant *iso2022_variant) *enc_ISO2022 {
+	res := new(enc_ISO2022)
+	res.variant = variant
+	for i := range(iso2022_charsets) {
+		res.tables[i] = -1
+		if iso2022_charsets[i].table != "" {
+			if iso2022_charsets[i].width == 1 && iso2022_charsets[i].prefix == 0 {
+				res.tables[i] = Open8bit(iso2022_charsets[i].table)
+			} else {
+				res.tables[i] = OpenMultibyte(iso2022_charsets[i].table)
+			}
+		}
+	}
+	res.Reset()
+
+	return res
+}
+
+// Reset state of decoder and encoder for the new stream
+func (self *enc_ISO2022) Reset() {
+	self.g = [3]int{cs_ascii, -1, -1}
+	self.shift = false
+	self.second = 0
+	self.out = [3]int{cs_ascii, -1, -1}
+	self.oshift = false
+	self.started = false
+	self.pending = 0
+}
+
+// Parse escape sequence at the start of p. Returns character set and length of sequence.
+// Length is 0 if sequence is incomplete and -1 if sequence is unknown.
+func (self *enc_ISO2022) escape(p []byte) (int, int) {
+	partial := false
+	for _, cs := range(self.variant.decode) {
+		seqs := []string{iso2022_charsets[cs].esc}
+		if e := seqs[0]; len(e) == 3 && e[1] == '$' { // ESC $ F is short form of ESC $ ( F
+			seqs = append(seqs, "\x1b$(" + e[2:])
+		}
+
+		for _, e := range(seqs) {
+			if len(p) >= len(e) {
+				if string(p[:len(e)]) == e {
+					return cs, len(e)
+				}
+			} else if string(p) == e[:len(p)] {
+				partial = true
+			}
+		}
+	}
+
+	if partial {
+		return -1, 0
+	}
+
+	return -1, -1
+}
+
+// Rows of JIS X 0213 plane 2, other rows of 0x8F codes in EUC-JIS-2004 are JIS X 0212 ones
+var jis0213_plane2_rows = [...]bool{1: true, 3: true, 4: true, 5: true, 8: true, 12: true, 13: true, 14: true, 15: true,
+	78: true, 79: true, 80: true, 81: true, 82: true, 83: true, 84: true, 85: true, 86: true, 87: true, 88: true,
+	89: true, 90: true, 91: true, 92: true, 93: true, 94: true}
+
+// Check if 7-bit code is in the rows of character set
+func iso2022_row(cs int, code uint32) bool {
+	if cs != cs_jis0213_2 {
+		return true
+	}
+
+	row := int(code >> 8) - 0x20
+	return row > 0 && row < len(jis0213_plane2_rows) && jis0213_plane2_rows[row]
+}
+
+// Convert 7-bit code in character set to runes:
+func (self *enc_ISO2022) to_rune(cs int, code uint32) (rune, rune, bool) {
+	if !iso2022_row(cs, code) {
+		return 0, 0, false
+	}
+
+	switch cs {
+	case cs_ascii:
+		return rune(code), 0, true
+	case cs_jis_roman:
+		if code == 0x5c {
+			return 0xa5, 0, true
+		} else if code == 0x7e {
+			return 0x203e, 0, true
+		}
+		return rune(code), 0, true
+	}
+
+	id := self.tables[cs]
+	if id < 0 {
+		return 0, 0, false
+	}
+
+	info := &iso2022_charsets[cs]
+	if info.width == 1 && info.prefix == 0 {
+		r := ByteToRune(id, byte(code) | 0x80)
+		return r, 0, r != 0
+	}
+
+	if info.width == 2 {
+		code |= 0x8080
+	} else {
+		code |= 0x80
+	}
+	code |= info.prefix << uint(8 * info.width)
+
+	if r, ok := mb_to_rune(id, code); ok {
+		return r, 0, true
+	}
+
+	if r, ok := mb_to_runes(id, code); ok {
+		return r[0], r[1], true
+	}
+
+	return 0, 0, false
+}
+
+// Convert rune to 7-bit code in character set:
+func (self *enc_ISO2022) from_rune(cs int, r rune) (uint32, bool) {
+	switch cs {
+	case cs_ascii:
+		return uint32(r), r < 0x80
+	case cs_jis_roman:
+		if r == 0xa5 {
+			return 0x5c, true
+		} else if r == 0x203e {
+			return 0x7e, true
+		}
+		return uint32(r), r < 0x80 && r != 0x5c && r != 0x7e
+	}
+
+	id := self.tables[cs]
+	if id < 0 {
+		return 0, false
+	}
+
+	info := &iso2022_charsets[cs]
+	if info.width == 1 && info.prefix == 0 {
+		b := RuneToByte(id, r)
+		return uint32(b & 0x7f), b >= 0xa0
+	}
+
+	code, ok := mb_from_rune(id, r)
+	if !ok {
+		return 0, false
+	}
+
+	return iso2022_code(cs, code)
+}
+
+// Convert pair of runes to 7-bit code in character set (JIS X 0213):
+func (self *enc_ISO2022) from_runes(cs int, r1, r2 rune) (uint32, bool) {
+	id := self.tables[cs]
+	if id < 0 || iso2022_charsets[cs].width != 2 {
+		return 0, false
+	}
+
+	code, ok := mb_from_runes(id, r1, r2)
+	if !ok {
+		return 0, false
+	}
+
+	return iso2022_code(cs, code)
+}
+
+// Check if rune can be the first one of pair in character sets of encoder
+func (self *enc_ISO2022) pair_first(r rune) bool {
+	for _, cs := range(self.variant.encode) {
+		if id := self.tables[cs]; id >= 0 && iso2022_charsets[cs].width == 2 && mb_pair_first(id, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Convert code of multibyte table to 7-bit code in character set cs:
+func iso2022_code(cs int, code uint32) (uint32, bool) {
+	info := &iso2022_charsets[cs]
+	if code >> uint(8 * info.width) != info.prefix {
+		return 0, false
+	}
+
+	var res uint32 = 0
+	for i := info.width - 1; i >= 0; i-- {
+		b := (code >> uint(8 * i)) & 0xff
+		if b < 0xa1 || b > 0xfe {
+			return 0, false
+		}
+		res = res << 8 | (b & 0x7f)
+	}
+
+	return res, iso2022_row(cs, res)
+}
+
+func (self *enc_ISO2022) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	if self.second != 0 {
+		r := self.second
+		self.second = 0
+		return r, iso2022_charsets[self.current()].width
+	}
+
+	switch p[0] {
+	case esc:
+		if len(p) >= 2 && p[1] == 'N' { // Single shift
+			return self.decode(p[2:], self.g[2], 2)
+		}
+
+		cs, n := self.escape(p)
+		if n <= 0 {
+			return RuneError, n
+		}
+
+		self.g[iso2022_charsets[cs].g] = cs
+		return NoRune, n
+	case so:
+		if self.g[1] < 0 {
+			return RuneError, -1
+		}
+		self.shift = true
+		return NoRune, 1
+	case si:
+		self.shift = false
+		return NoRune, 1
+	}
+
+	if p[0] >= 0x80 {
+		return RuneError, -1
+	}
+
+	if p[0] < 0x20 || ((p[0] == 0x20 || p[0] == 0x7f) && !iso2022_set96(self.current())) { // Control characters and space
+		if (p[0] == '\r' || p[0] == '\n') && !self.variant.header { // Designations are valid till the end of line
+			self.g[1] = -1
+			self.g[2] = -1
+			self.shift = false
+		}
+		return rune(p[0]), 1
+	}
+
+	return self.decode(p, self.current(), 0)
+}
+
+// Current character set
+func (self *enc_ISO2022) current() int {
+	if self.shift {
+		return self.g[1]
+	}
+
+	return self.g[0]
+}
+
+// Decode one character in character set cs. n is length of prefix already processed.
+func (self *enc_ISO2022) decode(p []byte, cs int, n int) (rune, int) {
+	if cs < 0 {
+		return RuneError, -n - 1
+	}
+
+	width := iso2022_charsets[cs].width
+	if len(p) < width {
+		return RuneError, 0
+	}
+
+	lo, hi := byte(0x21), byte(0x7e)
+	if iso2022_set96(cs) {
+		lo, hi = 0x20, 0x7f
+	}
+
+	var code uint32 = 0
+	for i := 0; i < width; i++ {
+		if p[i] < lo || p[i] > hi {
+			return RuneError, -n - 1
+		}
+		code = code << 8 | uint32(p[i])
+	}
+
+	r1, r2, ok := self.to_rune(cs, code)
+	if !ok {
+		return RuneError, -n - width
+	}
+
+	if r2 != 0 {
+		self.second = r2
+		return r1, 0
+	}
+
+	return r1, n + width
+}
+
+func (self *enc_ISO2022) FullRune(p []byte) bool {
+	if len(p) < 1 {
+		return false
+	}
+
+	if self.second != 0 {
+		return true
+	}
+
+	if p[0] == esc {
+		if len(p) < 2 {
+			return false
+		}
+		if p[1] == 'N' {
+			if self.g[2] < 0 {
+				return true
+			}
+			return len(p) >= 2 + iso2022_charsets[self.g[2]].width
+		}
+		_, n := self.escape(p)
+		return n != 0
+	}
+
+	cs := self.current()
+	if cs < 0 || p[0] < 0x20 || p[0] > 0x7f || ((p[0] == 0x20 || p[0] == 0x7f) && !iso2022_set96(cs)) {
+		return true
+	}
+
+	return len(p) >= iso2022_charsets[cs].width
+}
+
+// Encoder keeps character which can be the first one of pair until the next character or Flush call (like mbcs_comb)
+func (self *enc_ISO2022) EncodeRune(p []byte, r rune) int {
+	if self.pending != 0 {
+		for _, cs := range(self.variant.encode) {
+			if code, ok := self.from_runes(cs, self.pending, r); ok {
+				n := self.encode(p, cs, code, r)
+				if n > 0 {
+					self.pending = 0
+				}
+				return n
+			}
+		}
+	}
+
+	state := self.save_state()
+	n := 0
+	if self.pending != 0 {
+		n = self.encode_rune(p, self.pending)
+		if n < 0 {
+			return -1
+		}
+	}
+
+	if self.pair_first(r) && self.encodable(r) {
+		self.pending = r
+		return n
+	}
+
+	l := self.encode_rune(p[n:], r)
+	if l < 0 {
+		self.restore_state(state)
+		return -1
+	}
+
+	self.pending = 0
+	return n + l
+}
+
+// Check if character can be encoded without changing state
+func (self *enc_ISO2022) encodable(r rune) bool {
+	for _, cs := range(self.variant.encode) {
+		if _, ok := self.from_rune(cs, r); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write one character in the first character set which has it
+func (self *enc_ISO2022) encode_rune(p []byte, r rune) int {
+	// Shifted G1 set is kept while it has characters (GB 2312 and CNS 11643 in ISO-2022-CN)
+	if cs := self.out[1]; self.oshift && cs >= 0 {
+		if code, ok := self.from_rune(cs, r); ok {
+			return self.encode(p, cs, code, r)
+		}
+	}
+
+	for _, cs := range(self.variant.encode) {
+		code, ok := self.from_rune(cs, r)
+		if ok {
+			return self.encode(p, cs, code, r)
+		}
+	}
+
+	return -1
+}
+
+// Write character with code in character set cs. State is changed only if p is big enough.
+func (self *enc_ISO2022) encode(p []byte, cs int, code uint32, r rune) int {
+	var buf [16]byte
+	n := 0
+	out := self.out
+	oshift := self.oshift
+	info := &iso2022_charsets[cs]
+
+	if self.variant.header && !self.started { // Designation of G1 character set
+		n += copy(buf[n:], iso2022_charsets[self.variant.encode[1]].esc)
+	}
+
+	switch info.g {
+	case 0:
+		if oshift {
+			buf[n] = si
+			n++
+			oshift = false
+		}
+		if out[0] != cs {
+			n += copy(buf[n:], info.esc)
+			out[0] = cs
+		}
+	case 1:
+		if out[1] != cs {
+			if !self.variant.header {
+				n += copy(buf[n:], info.esc)
+			}
+			out[1] = cs
+		}
+		if !oshift {
+			buf[n] = so
+			n++
+			oshift = true
+		}
+	case 2:
+		if out[2] != cs {
+			n += copy(buf[n:], info.esc)
+			out[2] = cs
+		}
+		buf[n] = esc
+		buf[n + 1] = 'N'
+		n += 2
+	}
+
+	if info.width == 2 {
+		buf[n] = byte(code >> 8)
+		n++
+	}
+	buf[n] = byte(code)
+	n++
+
+	if (r == '\r' || r == '\n') && !self.variant.header { // Designations of G1 and G2 are valid till the end of line
+		out[1] = -1
+		out[2] = -1
+	}
+
+	if len(p) < n {
+		return -1
+	}
+
+	copy(p, buf[:n])
+	self.out = out
+	self.oshift = oshift
+	self.started = true
+
+	return n
+}
+
+// Write pending character and return to ASCII at the end of text. Returns number of bytes written or -1 if p is too small.
+func (self *enc_ISO2022) Flush(p []byte) int {
+	state := self.save_state()
+	n := 0
+	if self.pending != 0 {
+		n = self.encode_rune(p, self.pending)
+		if n < 0 {
+			return -1
+		}
+	}
+
+	var seq string
+	if self.oshift {
+		seq = string([]byte{si})
+	} else if self.out[0] != cs_ascii {
+		seq = iso2022_charsets[cs_ascii].esc
+	}
+
+	if len(p) - n < len(seq) {
+		self.restore_state(state)
+		return -1
+	}
+
+	self.oshift = false
+	self.out[0] = cs_ascii
+	self.pending = 0
+
+	return n + copy(p[n:], seq)
+}
+
+type iso2022_state struct {
+	out     [3]int
+	oshift  bool
+	started bool
+	pending rune
+}
+
+func (self *enc_ISO2022) save_state() interface{} {
+	return iso2022_state{self.out, self.oshift, self.started, self.pending}
+}
+
+func (self *enc_ISO2022) restore_state(state interface{}) {
+	s := state.(iso2022_state)
+	self.out, self.oshift, self.started, self.pending = s.out, s.oshift, s.started, s.pending
+}
@@ -0,0 +1,145 @@
+package charenc
+
+import (
+	"errors"
+	"sync"
+)
+
+// Width is class of encoding by number of bytes per character
+type Width int
+
+const (
+	SingleByte Width = iota + 1 // One byte per character
+	MultiByte                   // Variable or fixed number of bytes per character without state
+	Stateful                    // Meaning of bytes depends on previous ones (escape sequences, byte order mark)
+)
+
+func (self Width) String() string {
+	switch self {
+	case SingleByte:
+		return "single"
+	case MultiByte:
+		return "multi"
+	case Stateful:
+		return "stateful"
+	}
+
+	return "unknown"
+}
+
+// Encoding describes encoding in Registry. New is called for each decoder or encoder, so codec returned by it
+// may keep state.
+type Encoding struct {
+	Name        string   // Canonical name
+	Aliases     []string
+	MIBenum     int      // IANA MIBenum (0 if encoding is not registered by IANA)
+	WHATWG      string   // Name in WHATWG Encoding Standard ("" if browsers don't support encoding)
+	Width       Width
+	Description string
+	New         func() CharacterEncoding
+}
+
+// Registry maps names of encodings to their descriptions. Names are case insensitive and all non alphanumeric
+// characters in them are equal ("ISO-8859-1" is the same as "iso_8859_1"). Registry is safe for concurrent use.
+type Registry struct {
+	lock   sync.RWMutex
+	byname map[string]*Encoding
+	list   []*Encoding
+}
+
+// NewRegistry creates empty registry
+func NewRegistry() *Registry {
+	return &Registry{byname: make(map[string]*Encoding)}
+}
+
+// Register adds encoding to registry. Names of encoding override names of encodings registered before.
+func (self *Registry) Register(e Encoding) error {
+	if e.Name == "" || e.New == nil {
+		return errors.New("charenc: encoding must have name and constructor")
+	}
+
+	e.Aliases = append([]string(nil), e.Aliases...)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, n := range(append([]string{e.Name}, e.Aliases...)) {
+		self.byname[charset_name(n)] = &e
+	}
+	self.list = append(self.list, &e)
+
+	return nil
+}
+
+// Lookup finds encoding by canonical name or alias (nil if not found). Returned description must not be changed.
+func (self *Registry) Lookup(name string) *Encoding {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	return self.byname[charset_name(name)]
+}
+
+// Encodings returns all encodings in order of registration. Encodings which canonical names are overridden
+// by encodings registered later are skipped.
+func (self *Registry) Encodings() []*Encoding {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	var res []*Encoding
+	for _, e := range(self.list) {
+		if self.byname[charset_name(e.Name)] == e {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// Encodings known to NewRuneDecoder, NewRuneEncoder, GetReader, GetWriter and other functions taking name of
+// encoding. Contains all built-in encodings.
+var DefaultRegistry = NewRegistry()
+
+// Register adds encoding to DefaultRegistry
+func Register(e Encoding) error {
+	return DefaultRegistry.Register(e)
+}
+
+// Lookup finds encoding in DefaultRegistry
+func Lookup(name string) *Encoding {
+	return DefaultRegistry.Lookup(name)
+}
+
+func init() {
+	for _, e := range(unicode_encodings) {
+		DefaultRegistry.Register(e)
+	}
+	for _, e := range(iso2022_encodings) {
+		DefaultRegistry.Register(e)
+	}
+	for _, e := range(utf7_encodings) {
+		DefaultRegistry.Register(e)
+	}
+
+	// Table based encodings: aliases are names of tables with the same canonical name
+	aliases := make(map[string][]string)
+	for _, t := range(names) {
+		if t.name != t.charset {
+			aliases[t.charset] = append(aliases[t.charset], t.name)
+		}
+	}
+	for _, t := range(mbnames) {
+		if t.name != t.charset {
+			aliases[t.charset] = append(aliases[t.charset], t.name)
+		}
+	}
+
+	for _, c := range(charsets) {
+		e := Encoding{c.name, aliases[c.name], c.mib, c.whatwg, SingleByte, c.descr, nil}
+		if id := OpenMultibyte(c.name); id >= 0 {
+			e.Width = MultiByte
+			e.New = func() CharacterEncoding { return open_mbcs(id) }
+		} else {
+			id := Open8bit(c.name)
+			e.New = func() CharacterEncoding { return bit8{id, false} }
+		}
+		DefaultRegistry.Register(e)
+	}
+}
@@ -0,0 +1,88 @@
+package charenc
+
+import (
+	"strings"
+)
+
+// Parse iconv suffixes of encoding name ("CP1251//TRANSLIT//IGNORE"). Returns name without suffixes and flags.
+// Unknown suffix is an error.
+func parse_suffixes(encoding string) (name string, translit, ignore, ok bool) {
+	parts := strings.Split(encoding, "//")
+	for _, s := range(parts[1:]) {
+		switch strings.ToUpper(s) {
+		case "TRANSLIT":
+			translit = true
+		case "IGNORE":
+			ignore = true
+		case "":
+		default:
+			return "", false, false, false
+		}
+	}
+
+	return parts[0], translit, ignore, true
+}
+
+// Decoder which skips invalid sequences (//IGNORE suffix)
+type ignore_decoder struct {
+	decoder RuneDecoder
+}
+
+func (self *ignore_decoder) DecodeRune(p []byte) (rune, int) {
+	r, l := self.decoder.DecodeRune(p)
+	if l < 0 {
+		return NoRune, -l
+	}
+
+	return r, l
+}
+
+func (self *ignore_decoder) DecodeLast(p []byte) (rune, int) {
+	return decode_last(self.decoder, p)
+}
+
+func (self *ignore_decoder) FullRune(p []byte) bool {
+	return self.decoder.FullRune(p)
+}
+
+func (self *ignore_decoder) Reset() {
+	reset_decoder(self.decoder)
+}
+
+// Encoder which skips characters which can not be encoded (//IGNORE suffix)
+type ignore_encoder struct {
+	encoder RuneEncoder
+}
+
+func (self *ignore_encoder) EncodeRune(p []byte, r rune) int {
+	if l := self.encoder.EncodeRune(p, r); l >= 0 {
+		return l
+	}
+
+	// Character is skipped only if it can not be encoded into large buffer, otherwise p is too small
+	var buf [64]byte
+	state := save_encoder(self.encoder)
+	l := self.encoder.EncodeRune(buf[:], r)
+	restore_encoder(self.encoder, state)
+	if l >= 0 {
+		return -1
+	}
+
+	return 0
+}
+
+func (self *ignore_encoder) Reset() {
+	reset_encoder(self.encoder)
+}
+
+func (self *ignore_encoder) Flush(p []byte) int {
+	return flush(self.encoder, p)
+}
+
+func (self *ignore_encoder) save_state() interface{} {
+	return save_encoder(self.encoder)
+}
+
+func (self *ignore_encoder) restore_state(state interface{}) {
+	restore_encoder(self.encoder, state)
+}
@@ -0,0 +1,302 @@
+package charenc
+
+import (
+	"strings"
+)
+
+// Tables are generated from mapping files in ../tables by tables/generate.go:
+//go:generate go run ../tables/generate.go -src ../tables -out .
+
+type pair struct {
+	chr byte
+	uchr rune
+}
+
+type tbls struct {
+	name string
+	charset string // canonical name of encoding
+	to_ucs [256]rune
+	from_ucs [256]pair
+	bestfit []pair // Windows best fit characters, sorted by character (nil if there are no best fit table)
+}
+
+// Metadata of table based encoding
+type charset struct {
+	name string   // canonical name of encoding
+	mib int       // IANA MIBenum (0 if not registered)
+	whatwg string // name in WHATWG Encoding Standard ("" if not supported)
+	descr string
+}
+
+type mbpair struct {
+	code uint32
+	uchr rune
+}
+
+type mbcomb struct {
+	code uint32
+	uchr [2]rune
+}
+
+// CJK index of WHATWG Encoding Standard, pointer is calculated from bytes by encoding specific formula
+type windex struct {
+	name string
+	to_ucs []rune     // code points by pointer (0 if pointer is not mapped)
+	from_ucs []mbpair // pointers used by encoder, sorted by character (nil if encoder doesn't use index)
+}
+
+type mbtbls struct {
+	name string
+	charset string    // canonical name of encoding
+	seqlen *[256]byte // length of sequence by the first byte (0 is invalid byte)
+	to_ucs []mbpair   // sorted by code
+	from_ucs []mbpair // sorted by character
+	comb []mbcomb     // codes representing two characters, sorted by code
+}
+
+func isalpha(c byte) bool {
+	return (c >= 97 && c <= 122) || (c >= 65 && c <= 90)
+}
+
+func isnumber(c byte) bool {
+	return (c >= 48 && c <= 58)
+}
+
+func isalnum(c byte) bool {
+	return isalpha(c) || isnumber(c)
+}
+
+// Get internal number of this encoding:
+func Open8bit(encoding string) int {
+	// We must convert name special way:
+	benc := make([]byte, len(encoding))
+	copy(benc, encoding)
+	for i := range(benc) {
+		if !isalnum(benc[i]) {
+			benc[i] = '_'
+		}
+	}
+	enc := strings.ToUpper(string(benc))
+
+	for i := range(names) {
+		if strings.ToUpper(names[i].name) == enc {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Convert one byte to rune in specific encoding (0 is error):
+func ByteToRune(codec int, b byte) rune {
+	if codec < 0 || codec >= len(names) {
+		return 0
+	}
+
+	return names[codec].to_ucs[b]
+}
+
+// Convert one rune to byte using Windows best fit table if character is not in encoding (0 is error):
+func RuneToByteBestFit(codec int, ch rune) byte {
+	if codec < 0 || codec >= len(names) {
+		return 0
+	}
+
+	if b := RuneToByte(codec, ch); b != 0 || ch == 0 {
+		return b
+	}
+
+	tbl := names[codec].bestfit
+	a, b := 0, len(tbl)
+	for a < b {
+		c := (a + b) / 2
+		if tbl[c].uchr < ch {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(tbl) && tbl[a].uchr == ch {
+		return tbl[a].chr
+	}
+
+	return 0
+}
+
+// Convert one rune to byte in specific encoding (0 is error):
+func RuneToByte(codec int, ch rune) byte {
+	if codec < 0 || codec >= len(names) {
+		return 0
+	}
+
+	return names[codec].from_rune(ch)
+}
+
+// Convert one rune to byte using table (0 is error):
+func (self *tbls) from_rune(ch rune) byte {
+	var c int
+
+	a := 0
+	b := len(self.from_ucs) - 1
+
+	if self.from_ucs[a].uchr > ch || self.from_ucs[b].uchr < ch {
+		return 0
+	}
+
+	for b - a > 1 {
+		c = (a + b) / 2
+		if self.from_ucs[c].uchr < ch {
+			a = c
+		} else {
+			b = c
+		}
+	}
+
+	if self.from_ucs[a].uchr == ch {
+		return self.from_ucs[a].chr
+	}
+
+	if self.from_ucs[b].uchr == ch {
+		return self.from_ucs[b].chr
+	}
+
+	return 0
+}
+
+// Get internal number of multibyte encoding:
+func OpenMultibyte(encoding string) int {
+	// We must convert name special way:
+	benc := make([]byte, len(encoding))
+	copy(benc, encoding)
+	for i := range(benc) {
+		if !isalnum(benc[i]) {
+			benc[i] = '_'
+		}
+	}
+	enc := strings.ToUpper(string(benc))
+
+	for i := range(mbnames) {
+		if strings.ToUpper(mbnames[i].name) == enc {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Length of sequence starting from byte b (0 is error):
+func mb_seqlen(codec int, b byte) int {
+	return int(mbnames[codec].seqlen[b])
+}
+
+// Convert code to rune in specific encoding:
+func mb_to_rune(codec int, code uint32) (rune, bool) {
+	tbl := mbnames[codec].to_ucs
+	a, b := 0, len(tbl)
+	for a < b {
+		c := (a + b) / 2
+		if tbl[c].code < code {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(tbl) && tbl[a].code == code {
+		return tbl[a].uchr, true
+	}
+
+	return 0, false
+}
+
+// Convert code to pair of runes in specific encoding:
+func mb_to_runes(codec int, code uint32) ([2]rune, bool) {
+	tbl := mbnames[codec].comb
+	a, b := 0, len(tbl)
+	for a < b {
+		c := (a + b) / 2
+		if tbl[c].code < code {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(tbl) && tbl[a].code == code {
+		return tbl[a].uchr, true
+	}
+
+	return [2]rune{}, false
+}
+
+// Convert rune to code in specific encoding:
+func mb_from_rune(codec int, ch rune) (uint32, bool) {
+	tbl := mbnames[codec].from_ucs
+	a, b := 0, len(tbl)
+	for a < b {
+		c := (a + b) / 2
+		if tbl[c].uchr < ch {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(tbl) && tbl[a].uchr == ch {
+		return tbl[a].code, true
+	}
+
+	return 0, false
+}
+
+// Convert pointer to rune using WHATWG index (0 if pointer is not mapped):
+func (self *windex) code_point(ptr int) rune {
+	if ptr < 0 || ptr >= len(self.to_ucs) {
+		return 0
+	}
+
+	return self.to_ucs[ptr]
+}
+
+// Convert rune to pointer using WHATWG index (-1 if rune is not in index):
+func (self *windex) pointer(ch rune) int {
+	tbl := self.from_ucs
+	a, b := 0, len(tbl)
+	for a < b {
+		c := (a + b) / 2
+		if tbl[c].uchr < ch {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(tbl) && tbl[a].uchr == ch {
+		return int(tbl[a].code)
+	}
+
+	return -1
+}
+
+// Check if character can be the first one of pair in specific encoding:
+func mb_pair_first(codec int, ch rune) bool {
+	for _, c := range(mbnames[codec].comb) {
+		if c.uchr[0] == ch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Convert pair of runes to code in specific encoding:
+func mb_from_runes(codec int, ch1, ch2 rune) (uint32, bool) {
+	for _, c := range(mbnames[codec].comb) {
+		if c.uchr[0] == ch1 && c.uchr[1] == ch2 {
+			return c.code, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,32 @@
+package charenc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Generated tables must match mapping files in ../tables (run go generate after changing them)
+func TestTablesUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generator is slow")
+	}
+	if _, err := os.Stat("../tables/generate.go"); err != nil {
+		t.Skip("generator is not found")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not found")
+	}
+
+	// Generator reads mapping files in another process, walk them so go test doesn't cache result after changes
+	filepath.Walk("../tables", func(path string, info os.FileInfo, err error) error {
+		return err
+	})
+
+	out, err := exec.Command(gocmd, "run", "../tables/generate.go", "-src", "../tables", "-out", ".", "-check").CombinedOutput()
+	if err != nil {
+		t.Fatalf("generated tables are not up to date: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,170 @@
+package charenc
+
+import (
+	"strings"
+)
+
+// Transliteration replaces characters which can not be encoded by approximate ones: typographic quotes
+// by ASCII quotes, letters with diacritics by base letters, ligatures by several letters, Cyrillic and
+// Greek letters by Latin ones. Replacement is transliterated again if it can not be encoded too.
+
+type trpair struct {
+	uchr rune
+	repl string
+}
+
+// Maximal depth of transliteration of replacements
+const translit_depth = 4
+
+// Replacements which are tried before decomposition (translit_decomp from tablestr.go):
+var translit_table = map[rune]string{
+	// Quotes, dashes and punctuation:
+	0x00AB: "<<", 0x00BB: ">>", 0x2018: "'", 0x2019: "'", 0x201A: "'", 0x201B: "'",
+	0x201C: "\"", 0x201D: "\"", 0x201E: "\"", 0x201F: "\"", 0x2039: "<", 0x203A: ">",
+	0x2032: "'", 0x2033: "\"", 0x00B4: "'", 0x02B9: "'", 0x02BA: "\"", 0x02BB: "'", 0x02BC: "'",
+	0x2010: "-", 0x2011: "-", 0x2012: "-", 0x2013: "-", 0x2014: "-", 0x2015: "-", 0x2212: "-",
+	0x2026: "...", 0x2022: "o", 0x00B7: ".", 0x2044: "/", 0x2215: "/", 0x00A8: "\"",
+	0x00AD: "", 0x200B: "", 0x200C: "", 0x200D: "", 0x2060: "", 0xFEFF: "",
+	// Symbols:
+	0x20AC: "EUR", 0x00A2: "c", 0x00A3: "GBP", 0x00A5: "JPY", 0x00A9: "(C)", 0x00AE: "(R)",
+	0x2122: "(TM)", 0x00B0: "deg", 0x00B1: "+-", 0x00D7: "x", 0x00F7: ":", 0x00A7: "S", 0x00B6: "P",
+	0x2116: "No", 0x00A6: "|", 0x00AC: "!", 0x2190: "<-", 0x2192: "->", 0x2264: "<=", 0x2265: ">=",
+	0x2260: "!=", 0x2248: "~", 0x221E: "inf",
+	// Latin letters without decomposition:
+	0x00C6: "AE", 0x00E6: "ae", 0x0152: "OE", 0x0153: "oe", 0x00DF: "ss", 0x1E9E: "SS",
+	0x00D8: "O", 0x00F8: "o", 0x0110: "D", 0x0111: "d", 0x00D0: "D", 0x00F0: "d",
+	0x0141: "L", 0x0142: "l", 0x00DE: "TH", 0x00FE: "th", 0x0126: "H", 0x0127: "h",
+	0x0131: "i", 0x014A: "NG", 0x014B: "ng", 0x0166: "T", 0x0167: "t", 0x0138: "q",
+	// Cyrillic letters:
+	0x0430: "a", 0x0431: "b", 0x0432: "v", 0x0433: "g", 0x0434: "d", 0x0435: "e", 0x0436: "zh", 0x0437: "z",
+	0x0438: "i", 0x0439: "j", 0x043A: "k", 0x043B: "l", 0x043C: "m", 0x043D: "n", 0x043E: "o", 0x043F: "p",
+	0x0440: "r", 0x0441: "s", 0x0442: "t", 0x0443: "u", 0x0444: "f", 0x0445: "kh", 0x0446: "ts", 0x0447: "ch",
+	0x0448: "sh", 0x0449: "shch", 0x044A: "\"", 0x044B: "y", 0x044C: "'", 0x044D: "e", 0x044E: "yu", 0x044F: "ya",
+	0x0451: "yo", 0x0452: "dj", 0x0453: "gj", 0x0454: "ye", 0x0455: "dz", 0x0456: "i", 0x0457: "yi", 0x0458: "j",
+	0x0459: "lj", 0x045A: "nj", 0x045B: "c", 0x045C: "kj", 0x045E: "u", 0x045F: "dzh", 0x0491: "g",
+	// Greek letters:
+	0x03B1: "a", 0x03B2: "v", 0x03B3: "g", 0x03B4: "d", 0x03B5: "e", 0x03B6: "z", 0x03B7: "i", 0x03B8: "th",
+	0x03B9: "i", 0x03BA: "k", 0x03BB: "l", 0x03BC: "m", 0x03BD: "n", 0x03BE: "x", 0x03BF: "o", 0x03C0: "p",
+	0x03C1: "r", 0x03C2: "s", 0x03C3: "s", 0x03C4: "t", 0x03C5: "y", 0x03C6: "f", 0x03C7: "ch", 0x03C8: "ps",
+	0x03C9: "o",
+}
+
+func init() {
+	// Capital Cyrillic and Greek letters:
+	for r, s := range(translit_table) {
+		var c rune
+		switch {
+		case r >= 0x0430 && r <= 0x044F:
+			c = r - 0x20
+		case r >= 0x0450 && r <= 0x045F:
+			c = r - 0x50
+		case r == 0x0491:
+			c = 0x0490
+		case r >= 0x03B1 && r <= 0x03C9 && r != 0x03C2:
+			c = r - 0x20
+		default:
+			continue
+		}
+		translit_table[c] = strings.ToUpper(s[:1]) + s[1:]
+	}
+}
+
+// Find decomposition of character:
+func translit_decompose(r rune) (string, bool) {
+	a, b := 0, len(translit_decomp)
+	for a < b {
+		c := (a + b) / 2
+		if translit_decomp[c].uchr < r {
+			a = c + 1
+		} else {
+			b = c
+		}
+	}
+
+	if a < len(translit_decomp) && translit_decomp[a].uchr == r {
+		return translit_decomp[a].repl, true
+	}
+
+	return "", false
+}
+
+// Possible replacements of character in order of preference
+func translit_candidates(r rune) []string {
+	var res []string
+	if s, ok := translit_table[r]; ok {
+		res = append(res, s)
+	}
+	if s, ok := translit_decompose(r); ok {
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// Encoder which transliterates characters which can not be encoded by the original one
+type translit_encoder struct {
+	encoder RuneEncoder
+}
+
+// NewTranslitEncoder returns encoder which replaces characters which can not be encoded by encoder
+// with approximate ones (like iconv //TRANSLIT). If there is no suitable replacement EncodeRune fails as usual.
+func NewTranslitEncoder(encoder RuneEncoder) RuneEncoder {
+	if encoder == nil {
+		return nil
+	}
+
+	return &translit_encoder{encoder}
+}
+
+func (self *translit_encoder) EncodeRune(p []byte, r rune) int {
+	return self.encode(p, r, 0)
+}
+
+func (self *translit_encoder) encode(p []byte, r rune, depth int) int {
+	if l := self.encoder.EncodeRune(p, r); l >= 0 {
+		return l
+	}
+	if depth >= translit_depth {
+		return -1
+	}
+
+	for _, s := range(translit_candidates(r)) {
+		if l := self.encode_string(p, s, depth + 1); l >= 0 {
+			return l
+		}
+	}
+
+	return -1
+}
+
+// Encode replacement. State of stateful encoder (ISO-2022 for example) is restored if it fails in the middle.
+func (self *translit_encoder) encode_string(p []byte, s string, depth int) int {
+	state := save_encoder(self.encoder)
+	n := 0
+	for _, r := range(s) {
+		l := self.encode(p[n:], r, depth)
+		if l < 0 {
+			restore_encoder(self.encoder, state)
+			return -1
+		}
+		n += l
+	}
+
+	return n
+}
+
+func (self *translit_encoder) Reset() {
+	reset_encoder(self.encoder)
+}
+
+func (self *translit_encoder) Flush(p []byte) int {
+	return flush(self.encoder, p)
+}
+
+func (self *translit_encoder) save_state() interface{} {
+	return save_encoder(self.encoder)
+}
+
+func (self *translit_encoder) restore_state(state interface{}) {
+	restore_encoder(self.encoder, state)
+}
@@ -0,0 +1,279 @@
+package charenc
+
+// UTF-7 (RFC 2152) and IMAP modified UTF-7 (RFC 3501). ASCII characters represent themselves, other characters
+// are written as base64 encoded UTF-16 between shift character ('+' or '&') and optional (required in IMAP) '-'.
+// Base64 bits are not aligned to bytes, so both decoder and encoder keep state and must be created for each stream.
+
+type utf7_variant struct {
+	shift    byte   // Character which starts base64
+	alphabet string // Base64 alphabet
+	strict   bool   // Only printable ASCII is allowed in the input and base64 is always terminated by '-'
+	name     string // Canonical name of encoding
+}
+
+var (
+	utf7 = utf7_variant{'+', "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/", false, "UTF-7"}
+	utf7_imap = utf7_variant{'&', "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+,", true, "UTF-7-IMAP"}
+)
+
+func utf7_new(variant *utf7_variant) func() CharacterEncoding {
+	return func() CharacterEncoding {
+		return &enc_UTF7{variant: variant}
+	}
+}
+
+var utf7_encodings = []Encoding{
+	{"UTF-7", []string{"UTF7", "UNICODE-1-1-UTF-7", "CSUNICODE11UTF7"}, 1012, "", Stateful,
+		"Unicode UTF-7 for mail (RFC 2152)", utf7_new(&utf7)},
+	{"UTF-7-IMAP", []string{"UTF7-IMAP", "IMAP-UTF-7", "IMAP4-UTF-7"}, 1020, "", Stateful,
+		"Modified UTF-7 for IMAP mailbox names (RFC 3501)", utf7_new(&utf7_imap)},
+}
+
+type enc_UTF7 struct {
+	variant *utf7_variant
+
+	// Decoder state:
+	shifted bool   // Base64 is active
+	bits    uint32 // Bits of base64 which are not decoded yet
+	nbits   uint   // Number of bits (less than 6)
+
+	// Encoder state:
+	oshifted bool
+	obits    uint32
+	onbits   uint
+}
+
+// Reset state of decoder and encoder for the new stream
+func (self *enc_UTF7) Reset() {
+	self.shifted = false
+	self.bits, self.nbits = 0, 0
+	self.oshifted = false
+	self.obits, self.onbits = 0, 0
+}
+
+// Value of base64 character (-1 if c is not in the alphabet)
+func (self *enc_UTF7) base64(c byte) int {
+	for i := 0; i < 64; i++ {
+		if self.variant.alphabet[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (self *enc_UTF7) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	if self.shifted {
+		r, n := self.decode(p)
+		if n != 0 || len(p) > 0 && self.base64(p[0]) >= 0 {
+			return r, n
+		}
+		self.shifted = false // p starts with character which terminates base64
+	}
+
+	c := p[0]
+	if c >= 0x80 || self.variant.strict && (c < 0x20 || c == 0x7f) {
+		return RuneError, -1
+	}
+	if c != self.variant.shift {
+		return rune(c), 1
+	}
+
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+	if p[1] == '-' { // "+-" is '+'
+		return rune(c), 2
+	}
+
+	self.shifted = true
+	self.bits, self.nbits = 0, 0
+	return NoRune, 1
+}
+
+// Decode base64 until character is complete. State is changed only if character or terminator is decoded.
+// Returns 0 length if p is too short and if p starts with character which terminates base64 without '-'.
+func (self *enc_UTF7) decode(p []byte) (rune, int) {
+	bits, nbits := self.bits, self.nbits
+	var high rune // High surrogate
+	for i := 0; i < len(p); i++ {
+		v := self.base64(p[i])
+		if v < 0 { // End of base64
+			n := i
+			if p[i] == '-' {
+				n++
+			} else if self.variant.strict {
+				self.shifted = false
+				return RuneError, -(i + 1)
+			}
+			if n == 0 { // Decoded as the first character after base64
+				return RuneError, 0
+			}
+
+			self.shifted = false
+			if i > 0 || bits & (1 << nbits - 1) != 0 { // Incomplete character or not zero padding bits
+				return RuneError, -n
+			}
+			return NoRune, n
+		}
+
+		bits = bits << 6 | uint32(v)
+		nbits += 6
+		if nbits < 16 {
+			continue
+		}
+
+		nbits -= 16
+		u := rune(bits >> nbits & 0xffff)
+		bits &= 1 << nbits - 1
+		switch {
+		case high != 0 && u >= 0xdc00 && u <= 0xdfff:
+			u = 0x10000 + (high - 0xd800) << 10 + (u - 0xdc00)
+		case high != 0 || u >= 0xdc00 && u <= 0xdfff:
+			self.bits, self.nbits = bits, nbits
+			return RuneError, -(i + 1)
+		case u >= 0xd800 && u <= 0xdbff:
+			high = u
+			continue
+		}
+
+		self.bits, self.nbits = bits, nbits
+		return u, i + 1
+	}
+
+	return RuneError, 0
+}
+
+func (self *enc_UTF7) FullRune(p []byte) bool {
+	c := *self
+	_, n := c.DecodeRune(p)
+	return n != 0
+}
+
+// Character is written directly without base64
+func (self *enc_UTF7) direct(r rune) bool {
+	if self.variant.strict {
+		return r >= 0x20 && r < 0x7f
+	}
+
+	// RFC 2152 Set D and white space
+	switch {
+	case r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+		return true
+	case r == '\'' || r == '(' || r == ')' || r == ',' || r == '-' || r == '.' || r == '/' || r == ':' || r == '?':
+		return true
+	case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+		return true
+	}
+
+	return r == rune(self.variant.shift)
+}
+
+// Write rest of base64 bits and terminator. '-' is optional in UTF-7 if the next character isn't base64 one or '-'.
+func (self *enc_UTF7) unshift(buf []byte, next rune) int {
+	n := 0
+	if self.onbits > 0 {
+		buf[n] = self.variant.alphabet[self.obits << (6 - self.onbits) & 0x3f]
+		n++
+	}
+	if self.variant.strict || next < 0 || next == '-' || next < 0x80 && self.base64(byte(next)) >= 0 {
+		buf[n] = '-'
+		n++
+	}
+
+	return n
+}
+
+func (self *enc_UTF7) EncodeRune(p []byte, r rune) int {
+	var buf [16]byte
+	n := 0
+
+	if self.direct(r) {
+		if self.oshifted {
+			n += self.unshift(buf[n:], r)
+		}
+		buf[n] = byte(r)
+		n++
+		if r == rune(self.variant.shift) {
+			buf[n] = '-'
+			n++
+		}
+
+		if len(p) < n {
+			return -1
+		}
+		self.oshifted = false
+		self.obits, self.onbits = 0, 0
+		return copy(p, buf[:n])
+	}
+
+	if r < 0 || r > 0x10ffff || r >= 0xd800 && r <= 0xdfff {
+		return -1
+	}
+
+	if !self.oshifted {
+		buf[n] = self.variant.shift
+		n++
+	}
+
+	units := []uint32{uint32(r)}
+	if r >= 0x10000 {
+		r -= 0x10000
+		units = []uint32{0xd800 + uint32(r >> 10), 0xdc00 + uint32(r & 0x3ff)}
+	}
+
+	bits, nbits := self.obits, self.onbits
+	for _, u := range(units) {
+		bits = bits << 16 | u
+		nbits += 16
+		for nbits >= 6 {
+			nbits -= 6
+			buf[n] = self.variant.alphabet[bits >> nbits & 0x3f]
+			n++
+		}
+		bits &= 1 << nbits - 1
+	}
+
+	if len(p) < n {
+		return -1
+	}
+	self.oshifted = true
+	self.obits, self.onbits = bits, nbits
+	return copy(p, buf[:n])
+}
+
+// Terminate base64 at the end of text. Returns number of bytes written or -1 if p is too small.
+func (self *enc_UTF7) Flush(p []byte) int {
+	if !self.oshifted {
+		return 0
+	}
+
+	var buf [2]byte
+	n := self.unshift(buf[:], -1)
+	if len(p) < n {
+		return -1
+	}
+
+	self.oshifted = false
+	self.obits, self.onbits = 0, 0
+	return copy(p, buf[:n])
+}
+
+type utf7_state struct {
+	oshifted bool
+	obits    uint32
+	onbits   uint
+}
+
+func (self *enc_UTF7) save_state() interface{} {
+	return utf7_state{self.oshifted, self.obits, self.onbits}
+}
+
+func (self *enc_UTF7) restore_state(state interface{}) {
+	s := state.(utf7_state)
+	self.oshifted, self.obits, self.onbits = s.oshifted, s.obits, s.onbits
+}
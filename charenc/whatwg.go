@@ -0,0 +1,1045 @@
+package charenc
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// WHATWG Encoding Standard (https://encoding.spec.whatwg.org/) defines how browsers resolve labels and decode
+// documents. Labels have their own table: "latin1", "ascii" and "iso-8859-1" are windows-1252, "iso-8859-9" is
+// windows-1254, "euc-kr" is Unified Hangul Code and so on. Single byte and CJK encodings use WHATWG indexes
+// (tableswhatwg.go), CJK decoders and encoders follow algorithms of the standard.
+
+// Single byte encoding with WHATWG index
+type whatwg_sbcs struct {
+	tbl *tbls
+}
+
+func (self whatwg_sbcs) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	r := self.tbl.to_ucs[p[0]]
+	if r == 0 && p[0] != 0 {
+		return RuneError, -1
+	}
+
+	return r, 1
+}
+
+func (self whatwg_sbcs) FullRune(p []byte) bool {
+	return len(p) >= 1
+}
+
+func (self whatwg_sbcs) EncodeRune(p []byte, r rune) int {
+	if len(p) < 1 {
+		return -1
+	}
+
+	b := self.tbl.from_rune(r)
+	if b == 0 && r != 0 {
+		return -1
+	}
+
+	p[0] = b
+	return 1
+}
+
+func whatwg_table(name string) *tbls {
+	for i := range(whatwg_tables) {
+		if whatwg_tables[i].name == name {
+			return &whatwg_tables[i]
+		}
+	}
+
+	return nil
+}
+
+// UTF-8 decoder which reports maximal subparts of invalid sequences as errors ("\xF0\x9F\x98A" has one
+// invalid sequence of three bytes)
+type whatwg_utf8 struct {
+	enc_UTF8
+}
+
+func (self whatwg_utf8) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+	if p[0] < 0x80 {
+		return rune(p[0]), 1
+	}
+
+	n := 0
+	lo, hi := byte(0x80), byte(0xbf)
+	switch b := p[0]; {
+	case b >= 0xc2 && b <= 0xdf:
+		n = 2
+	case b >= 0xe0 && b <= 0xef:
+		n = 3
+		if b == 0xe0 {
+			lo = 0xa0
+		} else if b == 0xed {
+			hi = 0x9f
+		}
+	case b >= 0xf0 && b <= 0xf4:
+		n = 4
+		if b == 0xf0 {
+			lo = 0x90
+		} else if b == 0xf4 {
+			hi = 0x8f
+		}
+	default:
+		return RuneError, -1
+	}
+
+	for i := 1; i < n; i++ {
+		if i >= len(p) {
+			return RuneError, 0
+		}
+		if p[i] < lo || p[i] > hi {
+			return RuneError, -i
+		}
+		lo, hi = 0x80, 0xbf
+	}
+
+	r, _ := utf8.DecodeRune(p[:n])
+	return r, n
+}
+
+func (self whatwg_utf8) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+// Replacement encoding is used for labels of encodings which are dangerous for browsers (ISO-2022-KR,
+// HZ-GB-2312 and others): the whole input is one error. Encoder writes UTF-8.
+type whatwg_replacement struct {
+	enc_UTF8
+	done bool // Error has reported
+}
+
+func (self *whatwg_replacement) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+	if self.done {
+		return NoRune, len(p)
+	}
+
+	self.done = true
+	return RuneError, -1
+}
+
+func (self *whatwg_replacement) FullRune(p []byte) bool {
+	return len(p) >= 1
+}
+
+func (self *whatwg_replacement) Reset() {
+	self.done = false
+}
+
+// x-user-defined maps bytes 0x80..0xFF to U+F780..U+F7FF
+type whatwg_user_defined struct { }
+
+func (self whatwg_user_defined) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+	if p[0] < 0x80 {
+		return rune(p[0]), 1
+	}
+
+	return 0xf780 + rune(p[0]) - 0x80, 1
+}
+
+func (self whatwg_user_defined) FullRune(p []byte) bool {
+	return len(p) >= 1
+}
+
+func (self whatwg_user_defined) EncodeRune(p []byte, r rune) int {
+	if len(p) < 1 {
+		return -1
+	}
+
+	if r < 0x80 {
+		p[0] = byte(r)
+	} else if r >= 0xf780 && r <= 0xf7ff {
+		p[0] = byte(r - 0xf780 + 0x80)
+	} else {
+		return -1
+	}
+
+	return 1
+}
+
+// gb18030 and GBK share decoder which maps 0x80 to euro sign. GBK encoder writes only one and two bytes codes and
+// euro sign as 0x80. Four bytes codes use index gb18030 ranges where pointer 7457 is U+E7C7.
+type whatwg_gb18030 struct {
+	index *windex
+	gbk   bool
+}
+
+// Index gb18030 ranges code point (-1 if pointer is not mapped)
+func whatwg_gb18030_code_point(ptr int) rune {
+	if ptr == 7457 {
+		return 0xe7c7
+	}
+
+	r, ok := gb18030_to_rune(ptr)
+	if !ok {
+		return -1
+	}
+
+	return r
+}
+
+// Index gb18030 ranges pointer: the last range which starts at or below code point is used without further checks
+func whatwg_gb18030_pointer(r rune) int {
+	if r == 0xe7c7 {
+		return 7457
+	}
+	if r >= 0x10000 {
+		return int(r - 0x10000) + gb18030_supp
+	}
+
+	a, b := 0, len(gb18030_ranges)
+	for b - a > 1 {
+		c := (a + b) / 2
+		if gb18030_ranges[c].uchr <= r {
+			a = c
+		} else {
+			b = c
+		}
+	}
+
+	return int(gb18030_ranges[a].code) + int(r - gb18030_ranges[a].uchr)
+}
+
+func (self whatwg_gb18030) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	if b < 0x80 {
+		return rune(b), 1
+	}
+	if b == 0x80 {
+		return 0x20ac, 1
+	}
+	if b == 0xff {
+		return RuneError, -1
+	}
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+
+	// Second, third and fourth bytes of invalid four bytes code are decoded again
+	if gb18030_four(p) {
+		if len(p) < 3 {
+			return RuneError, 0
+		}
+		if p[2] < 0x81 || p[2] > 0xfe {
+			return RuneError, -1
+		}
+		if len(p) < 4 {
+			return RuneError, 0
+		}
+		if p[3] < 0x30 || p[3] > 0x39 {
+			return RuneError, -1
+		}
+
+		ptr := (((int(b) - 0x81) * 10 + int(p[1]) - 0x30) * 126 + int(p[2]) - 0x81) * 10 + int(p[3]) - 0x30
+		if r := whatwg_gb18030_code_point(ptr); r >= 0 {
+			return r, 4
+		}
+		return RuneError, -4
+	}
+
+	if t := p[1]; t >= 0x40 && t != 0x7f && t != 0xff {
+		offset := 0x40
+		if t > 0x7f {
+			offset = 0x41
+		}
+		if r := self.index.code_point(int(b - 0x81) * 190 + int(t) - offset); r != 0 {
+			return r, 2
+		}
+	}
+
+	return whatwg_invalid(p)
+}
+
+func (self whatwg_gb18030) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+func (self whatwg_gb18030) EncodeRune(p []byte, r rune) int {
+	if r < 0x80 {
+		return whatwg_put(p, byte(r))
+	}
+	if r == 0xe5e5 {
+		return -1
+	}
+	if self.gbk && r == 0x20ac {
+		return whatwg_put(p, 0x80)
+	}
+
+	if ptr := self.index.pointer(r); ptr >= 0 {
+		trail := ptr % 190
+		if trail < 0x3f {
+			trail += 0x40
+		} else {
+			trail += 0x41
+		}
+		return whatwg_put(p, byte(ptr / 190 + 0x81), byte(trail))
+	}
+	if self.gbk {
+		return -1
+	}
+
+	ptr := whatwg_gb18030_pointer(r)
+	return whatwg_put(p, byte(ptr / 12600 + 0x81), byte(ptr / 1260 % 10 + 0x30), byte(ptr / 10 % 126 + 0x81),
+		byte(ptr % 10 + 0x30))
+}
+
+func whatwg_index(name string) *windex {
+	for i := range(whatwg_indexes) {
+		if whatwg_indexes[i].name == name {
+			return &whatwg_indexes[i]
+		}
+	}
+
+	return nil
+}
+
+// Error for two bytes sequence which is not mapped: ASCII byte after lead byte is not consumed
+func whatwg_invalid(p []byte) (rune, int) {
+	if p[1] < 0x80 {
+		return RuneError, -1
+	}
+
+	return RuneError, -2
+}
+
+// Write bytes of one character
+func whatwg_put(p []byte, b ...byte) int {
+	if len(p) < len(b) {
+		return -1
+	}
+
+	return copy(p, b)
+}
+
+// EUC-JP: JIS X 0208 and JIS X 0212 (decoder only) from WHATWG indexes, half width katakana after 0x8E
+type whatwg_euc_jp struct {
+	jis0208 *windex
+	jis0212 *windex
+}
+
+// Pointer of two bytes in 0xA1..0xFE (-1 if bytes are out of range)
+func euc_pointer(l, t byte) int {
+	if l < 0xa1 || l > 0xfe || t < 0xa1 || t > 0xfe {
+		return -1
+	}
+
+	return int(l - 0xa1) * 94 + int(t - 0xa1)
+}
+
+func (self whatwg_euc_jp) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	if b < 0x80 {
+		return rune(b), 1
+	}
+	if b != 0x8e && b != 0x8f && (b < 0xa1 || b > 0xfe) {
+		return RuneError, -1
+	}
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+
+	switch {
+	case b == 0x8e && p[1] >= 0xa1 && p[1] <= 0xdf:
+		return 0xff61 - 0xa1 + rune(p[1]), 2
+	case b == 0x8f && p[1] >= 0xa1 && p[1] <= 0xfe:
+		if len(p) < 3 {
+			return RuneError, 0
+		}
+		if r := self.jis0212.code_point(euc_pointer(p[1], p[2])); r != 0 {
+			return r, 3
+		}
+		if p[2] < 0x80 {
+			return RuneError, -2
+		}
+		return RuneError, -3
+	}
+
+	if r := self.jis0208.code_point(euc_pointer(b, p[1])); r != 0 {
+		return r, 2
+	}
+
+	return whatwg_invalid(p)
+}
+
+func (self whatwg_euc_jp) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+func (self whatwg_euc_jp) EncodeRune(p []byte, r rune) int {
+	switch {
+	case r < 0x80:
+		return whatwg_put(p, byte(r))
+	case r == 0xa5:
+		return whatwg_put(p, 0x5c)
+	case r == 0x203e:
+		return whatwg_put(p, 0x7e)
+	case r >= 0xff61 && r <= 0xff9f:
+		return whatwg_put(p, 0x8e, byte(r - 0xff61 + 0xa1))
+	case r == 0x2212:
+		r = 0xff0d
+	}
+
+	ptr := self.jis0208.pointer(r)
+	if ptr < 0 {
+		return -1
+	}
+
+	return whatwg_put(p, byte(ptr / 94 + 0xa1), byte(ptr % 94 + 0xa1))
+}
+
+// Shift_JIS: JIS X 0208 from WHATWG index with user defined area 0xF040..0xF9FC. Encoder uses Shift_JIS index
+// which excludes NEC selected IBM extensions of rows 89..92.
+type whatwg_shift_jis struct {
+	index *windex
+}
+
+func (self whatwg_shift_jis) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	switch {
+	case b <= 0x80:
+		return rune(b), 1
+	case b >= 0xa1 && b <= 0xdf:
+		return 0xff61 - 0xa1 + rune(b), 1
+	case b == 0xa0 || b > 0xfc:
+		return RuneError, -1
+	}
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+
+	t := p[1]
+	if t < 0x40 || t == 0x7f || t > 0xfc {
+		return whatwg_invalid(p)
+	}
+
+	lead, trail := 0x81, 0x40
+	if b >= 0xa0 {
+		lead = 0xc1
+	}
+	if t >= 0x7f {
+		trail = 0x41
+	}
+
+	ptr := (int(b) - lead) * 188 + int(t) - trail
+	if ptr >= 8836 && ptr <= 10715 {
+		return 0xe000 - 8836 + rune(ptr), 2
+	}
+	if r := self.index.code_point(ptr); r != 0 {
+		return r, 2
+	}
+
+	return whatwg_invalid(p)
+}
+
+func (self whatwg_shift_jis) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+func (self whatwg_shift_jis) EncodeRune(p []byte, r rune) int {
+	switch {
+	case r <= 0x80:
+		return whatwg_put(p, byte(r))
+	case r == 0xa5:
+		return whatwg_put(p, 0x5c)
+	case r == 0x203e:
+		return whatwg_put(p, 0x7e)
+	case r >= 0xff61 && r <= 0xff9f:
+		return whatwg_put(p, byte(r - 0xff61 + 0xa1))
+	case r == 0x2212:
+		r = 0xff0d
+	}
+
+	ptr := self.index.pointer(r)
+	if ptr < 0 {
+		return -1
+	}
+
+	lead, trail := ptr / 188, ptr % 188
+	if lead < 0x1f {
+		lead += 0x81
+	} else {
+		lead += 0xc1
+	}
+	if trail < 0x3f {
+		trail += 0x40
+	} else {
+		trail += 0x41
+	}
+
+	return whatwg_put(p, byte(lead), byte(trail))
+}
+
+// EUC-KR: Unified Hangul Code from WHATWG index
+type whatwg_euc_kr struct {
+	index *windex
+}
+
+func (self whatwg_euc_kr) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	if b < 0x80 {
+		return rune(b), 1
+	}
+	if b == 0x80 || b == 0xff {
+		return RuneError, -1
+	}
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+
+	if t := p[1]; t >= 0x41 && t <= 0xfe {
+		if r := self.index.code_point(int(b - 0x81) * 190 + int(t - 0x41)); r != 0 {
+			return r, 2
+		}
+	}
+
+	return whatwg_invalid(p)
+}
+
+func (self whatwg_euc_kr) FullRune(p []byte) bool {
+	_, n := self.DecodeRune(p)
+	return n != 0
+}
+
+func (self whatwg_euc_kr) EncodeRune(p []byte, r rune) int {
+	if r < 0x80 {
+		return whatwg_put(p, byte(r))
+	}
+
+	ptr := self.index.pointer(r)
+	if ptr < 0 {
+		return -1
+	}
+
+	return whatwg_put(p, byte(ptr / 190 + 0x81), byte(ptr % 190 + 0x41))
+}
+
+// Big5: Big5-HKSCS from WHATWG index, four codes are decoded as two runes. Encoder doesn't write HKSCS codes with
+// lead bytes below 0xA1.
+type whatwg_big5 struct {
+	index  *windex
+	second rune // Second rune of pair to return (0 if none)
+}
+
+func (self *whatwg_big5) DecodeRune(p []byte) (rune, int) {
+	if self.second != 0 {
+		r := self.second
+		self.second = 0
+		return r, 2
+	}
+
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	if b < 0x80 {
+		return rune(b), 1
+	}
+	if b == 0x80 || b == 0xff {
+		return RuneError, -1
+	}
+	if len(p) < 2 {
+		return RuneError, 0
+	}
+
+	t := p[1]
+	if t < 0x40 || (t > 0x7e && t < 0xa1) || t == 0xff {
+		return whatwg_invalid(p)
+	}
+
+	trail := 0x40
+	if t >= 0xa1 {
+		trail = 0x62
+	}
+
+	ptr := int(b - 0x81) * 157 + int(t) - trail
+	switch ptr {
+	case 1133:
+		self.second = 0x304
+		return 0xca, 0
+	case 1135:
+		self.second = 0x30c
+		return 0xca, 0
+	case 1164:
+		self.second = 0x304
+		return 0xea, 0
+	case 1166:
+		self.second = 0x30c
+		return 0xea, 0
+	}
+
+	if r := self.index.code_point(ptr); r != 0 {
+		return r, 2
+	}
+
+	return whatwg_invalid(p)
+}
+
+func (self *whatwg_big5) FullRune(p []byte) bool {
+	if self.second != 0 {
+		return true
+	}
+
+	// First rune of pair is returned with zero length
+	c := *self
+	r, n := c.DecodeRune(p)
+	return n != 0 || r != RuneError
+}
+
+func (self *whatwg_big5) EncodeRune(p []byte, r rune) int {
+	if r < 0x80 {
+		return whatwg_put(p, byte(r))
+	}
+
+	ptr := self.index.pointer(r)
+	if ptr < 0 {
+		return -1
+	}
+
+	trail := ptr % 157
+	if trail < 0x3f {
+		trail += 0x40
+	} else {
+		trail += 0x62
+	}
+
+	return whatwg_put(p, byte(ptr / 157 + 0x81), byte(trail))
+}
+
+func (self *whatwg_big5) Reset() {
+	self.second = 0
+}
+
+// ISO-2022-JP: ASCII, JIS X 0201 Roman and Katakana (decoder only) and JIS X 0208 from WHATWG index. Escape sequence
+// which follows escape sequence is an error. Encoder writes half width katakana as full width one.
+type whatwg_iso2022_jp struct {
+	jis0208  *windex
+	katakana *windex
+	state    int  // Character set of decoder
+	output   bool // Decoder has read escape sequence and no character after it
+	ostate   int  // Character set of encoder
+}
+
+func (self *whatwg_iso2022_jp) DecodeRune(p []byte) (rune, int) {
+	if len(p) < 1 {
+		return RuneError, 0
+	}
+
+	b := p[0]
+	if b == esc {
+		if len(p) >= 2 && p[1] != '$' && p[1] != '(' {
+			self.output = false
+			return RuneError, -1
+		}
+		if len(p) < 3 {
+			return RuneError, 0
+		}
+
+		switch string(p[1:3]) {
+		case "(B":
+			self.state = cs_ascii
+		case "(J":
+			self.state = cs_jis_roman
+		case "(I":
+			self.state = cs_jis_kana
+		case "$@", "$B":
+			self.state = cs_jis0208
+		default:
+			self.output = false
+			return RuneError, -1
+		}
+
+		if self.output {
+			return RuneError, -3
+		}
+		self.output = true
+		return NoRune, 3
+	}
+
+	self.output = false
+	switch self.state {
+	case cs_ascii:
+		if b < 0x80 && b != so && b != si {
+			return rune(b), 1
+		}
+	case cs_jis_roman:
+		switch {
+		case b == 0x5c:
+			return 0xa5, 1
+		case b == 0x7e:
+			return 0x203e, 1
+		case b < 0x80 && b != so && b != si:
+			return rune(b), 1
+		}
+	case cs_jis_kana:
+		if b >= 0x21 && b <= 0x5f {
+			return 0xff61 - 0x21 + rune(b), 1
+		}
+	case cs_jis0208:
+		if b >= 0x21 && b <= 0x7e {
+			if len(p) < 2 {
+				return RuneError, 0
+			}
+			if p[1] == esc {
+				return RuneError, -1
+			}
+			if p[1] >= 0x21 && p[1] <= 0x7e {
+				if r := self.jis0208.code_point(int(b - 0x21) * 94 + int(p[1] - 0x21)); r != 0 {
+					return r, 2
+				}
+			}
+			return RuneError, -2
+		}
+	}
+
+	return RuneError, -1
+}
+
+func (self *whatwg_iso2022_jp) FullRune(p []byte) bool {
+	c := *self
+	_, n := c.DecodeRune(p)
+	return n != 0
+}
+
+func (self *whatwg_iso2022_jp) EncodeRune(p []byte, r rune) int {
+	if r == so || r == si || r == esc {
+		return -1
+	}
+
+	var buf [8]byte
+	n := 0
+	state := self.ostate
+	switch {
+	case r < 0x80:
+		if state != cs_ascii && (state != cs_jis_roman || r == 0x5c || r == 0x7e) {
+			n += copy(buf[n:], "\x1b(B")
+			state = cs_ascii
+		}
+		buf[n] = byte(r)
+		n++
+	case r == 0xa5 || r == 0x203e:
+		if state != cs_jis_roman {
+			n += copy(buf[n:], "\x1b(J")
+			state = cs_jis_roman
+		}
+		if r == 0xa5 {
+			buf[n] = 0x5c
+		} else {
+			buf[n] = 0x7e
+		}
+		n++
+	default:
+		if r == 0x2212 {
+			r = 0xff0d
+		} else if r >= 0xff61 && r <= 0xff9f {
+			r = self.katakana.code_point(int(r - 0xff61))
+		}
+
+		ptr := self.jis0208.pointer(r)
+		if ptr < 0 {
+			return -1
+		}
+
+		if state != cs_jis0208 {
+			n += copy(buf[n:], "\x1b$B")
+			state = cs_jis0208
+		}
+		buf[n] = byte(ptr / 94 + 0x21)
+		buf[n + 1] = byte(ptr % 94 + 0x21)
+		n += 2
+	}
+
+	if len(p) < n {
+		return -1
+	}
+
+	self.ostate = state
+	return copy(p, buf[:n])
+}
+
+// Return to ASCII at the end of text
+func (self *whatwg_iso2022_jp) Flush(p []byte) int {
+	if self.ostate == cs_ascii {
+		return 0
+	}
+	if len(p) < 3 {
+		return -1
+	}
+
+	self.ostate = cs_ascii
+	return copy(p, "\x1b(B")
+}
+
+func (self *whatwg_iso2022_jp) Reset() {
+	self.state = cs_ascii
+	self.output = false
+	self.ostate = cs_ascii
+}
+
+func (self *whatwg_iso2022_jp) save_state() interface{} {
+	return self.ostate
+}
+
+func (self *whatwg_iso2022_jp) restore_state(state interface{}) {
+	self.ostate = state.(int)
+}
+
+func whatwg_new_sbcs(name string) func() CharacterEncoding {
+	return func() CharacterEncoding {
+		return whatwg_sbcs{whatwg_table(name)}
+	}
+}
+
+// Encodings of WHATWG Encoding Standard, aliases are labels
+var whatwg_encodings = []Encoding{
+	{"UTF-8", []string{"unicode-1-1-utf-8", "unicode11utf8", "unicode20utf8", "utf8", "x-unicode20utf8"},
+		106, "UTF-8", MultiByte, "Unicode UTF-8", func() CharacterEncoding { return whatwg_utf8{} }},
+	{"IBM866", []string{"866", "cp866", "csibm866"},
+		2086, "IBM866", SingleByte, "DOS Russian", whatwg_new_sbcs("ibm866")},
+	{"ISO-8859-2", []string{"csisolatin2", "iso-ir-101", "iso8859-2", "iso88592", "iso_8859-2", "iso_8859-2:1987", "l2", "latin2"},
+		5, "ISO-8859-2", SingleByte, "ISO 8859-2 Latin 2 Central European", whatwg_new_sbcs("iso-8859-2")},
+	{"ISO-8859-3", []string{"csisolatin3", "iso-ir-109", "iso8859-3", "iso88593", "iso_8859-3", "iso_8859-3:1988", "l3", "latin3"},
+		6, "ISO-8859-3", SingleByte, "ISO 8859-3 Latin 3 South European", whatwg_new_sbcs("iso-8859-3")},
+	{"ISO-8859-4", []string{"csisolatin4", "iso-ir-110", "iso8859-4", "iso88594", "iso_8859-4", "iso_8859-4:1988", "l4", "latin4"},
+		7, "ISO-8859-4", SingleByte, "ISO 8859-4 Latin 4 North European", whatwg_new_sbcs("iso-8859-4")},
+	{"ISO-8859-5", []string{"csisolatincyrillic", "cyrillic", "iso-ir-144", "iso8859-5", "iso88595", "iso_8859-5", "iso_8859-5:1988"},
+		8, "ISO-8859-5", SingleByte, "ISO 8859-5 Cyrillic", whatwg_new_sbcs("iso-8859-5")},
+	{"ISO-8859-6", []string{"arabic", "asmo-708", "csiso88596e", "csiso88596i", "csisolatinarabic", "ecma-114", "iso-8859-6-e",
+		"iso-8859-6-i", "iso-ir-127", "iso8859-6", "iso88596", "iso_8859-6", "iso_8859-6:1987"},
+		9, "ISO-8859-6", SingleByte, "ISO 8859-6 Arabic", whatwg_new_sbcs("iso-8859-6")},
+	{"ISO-8859-7", []string{"csisolatingreek", "ecma-118", "elot_928", "greek", "greek8", "iso-ir-126", "iso8859-7", "iso88597",
+		"iso_8859-7", "iso_8859-7:1987", "sun_eu_greek"},
+		10, "ISO-8859-7", SingleByte, "ISO 8859-7 Greek", whatwg_new_sbcs("iso-8859-7")},
+	{"ISO-8859-8", []string{"csiso88598e", "csisolatinhebrew", "hebrew", "iso-8859-8-e", "iso-ir-138", "iso8859-8", "iso88598",
+		"iso_8859-8", "iso_8859-8:1988", "visual"},
+		11, "ISO-8859-8", SingleByte, "ISO 8859-8 Hebrew (visual order)", whatwg_new_sbcs("iso-8859-8")},
+	{"ISO-8859-8-I", []string{"csiso88598i", "logical"},
+		85, "ISO-8859-8-I", SingleByte, "ISO 8859-8 Hebrew (logical order)", whatwg_new_sbcs("iso-8859-8")},
+	{"ISO-8859-10", []string{"csisolatin6", "iso-ir-157", "iso8859-10", "iso885910", "l6", "latin6"},
+		13, "ISO-8859-10", SingleByte, "ISO 8859-10 Latin 6 Nordic", whatwg_new_sbcs("iso-8859-10")},
+	{"ISO-8859-13", []string{"iso8859-13", "iso885913"},
+		109, "ISO-8859-13", SingleByte, "ISO 8859-13 Latin 7 Baltic Rim", whatwg_new_sbcs("iso-8859-13")},
+	{"ISO-8859-14", []string{"iso8859-14", "iso885914"},
+		110, "ISO-8859-14", SingleByte, "ISO 8859-14 Latin 8 Celtic", whatwg_new_sbcs("iso-8859-14")},
+	{"ISO-8859-15", []string{"csisolatin9", "iso8859-15", "iso885915", "iso_8859-15", "l9"},
+		111, "ISO-8859-15", SingleByte, "ISO 8859-15 Latin 9", whatwg_new_sbcs("iso-8859-15")},
+	{"ISO-8859-16", nil,
+		112, "ISO-8859-16", SingleByte, "ISO 8859-16 Latin 10", whatwg_new_sbcs("iso-8859-16")},
+	{"KOI8-R", []string{"cskoi8r", "koi", "koi8", "koi8_r"},
+		2084, "KOI8-R", SingleByte, "KOI8-R Russian", whatwg_new_sbcs("koi8-r")},
+	{"KOI8-U", []string{"koi8-ru"},
+		2088, "KOI8-U", SingleByte, "KOI8-U Ukrainian and Belarusian", whatwg_new_sbcs("koi8-u")},
+	{"macintosh", []string{"csmacintosh", "mac", "x-mac-roman"},
+		2027, "macintosh", SingleByte, "Mac Roman", whatwg_new_sbcs("macintosh")},
+	{"windows-874", []string{"dos-874", "iso-8859-11", "iso8859-11", "iso885911", "tis-620"},
+		2109, "windows-874", SingleByte, "Windows Thai", whatwg_new_sbcs("windows-874")},
+	{"windows-1250", []string{"cp1250", "x-cp1250"},
+		2250, "windows-1250", SingleByte, "Windows Central European", whatwg_new_sbcs("windows-1250")},
+	{"windows-1251", []string{"cp1251", "x-cp1251"},
+		2251, "windows-1251", SingleByte, "Windows Cyrillic", whatwg_new_sbcs("windows-1251")},
+	{"windows-1252", []string{"ansi_x3.4-1968", "ascii", "cp1252", "cp819", "csisolatin1", "ibm819", "iso-8859-1", "iso-ir-100",
+		"iso8859-1", "iso88591", "iso_8859-1", "iso_8859-1:1987", "l1", "latin1", "us-ascii", "x-cp1252"},
+		2252, "windows-1252", SingleByte, "Windows Western European", whatwg_new_sbcs("windows-1252")},
+	{"windows-1253", []string{"cp1253", "x-cp1253"},
+		2253, "windows-1253", SingleByte, "Windows Greek", whatwg_new_sbcs("windows-1253")},
+	{"windows-1254", []string{"cp1254", "csisolatin5", "iso-8859-9", "iso-ir-148", "iso8859-9", "iso88599", "iso_8859-9",
+		"iso_8859-9:1989", "l5", "latin5", "x-cp1254"},
+		2254, "windows-1254", SingleByte, "Windows Turkish", whatwg_new_sbcs("windows-1254")},
+	{"windows-1255", []string{"cp1255", "x-cp1255"},
+		2255, "windows-1255", SingleByte, "Windows Hebrew", whatwg_new_sbcs("windows-1255")},
+	{"windows-1256", []string{"cp1256", "x-cp1256"},
+		2256, "windows-1256", SingleByte, "Windows Arabic", whatwg_new_sbcs("windows-1256")},
+	{"windows-1257", []string{"cp1257", "x-cp1257"},
+		2257, "windows-1257", SingleByte, "Windows Baltic", whatwg_new_sbcs("windows-1257")},
+	{"windows-1258", []string{"cp1258", "x-cp1258"},
+		2258, "windows-1258", SingleByte, "Windows Vietnamese", whatwg_new_sbcs("windows-1258")},
+	{"x-mac-cyrillic", []string{"x-mac-ukrainian"},
+		0, "x-mac-cyrillic", SingleByte, "Mac Cyrillic", whatwg_new_sbcs("x-mac-cyrillic")},
+	{"GBK", []string{"chinese", "csgb2312", "csiso58gb231280", "gb2312", "gb_2312", "gb_2312-80", "iso-ir-58", "x-gbk"},
+		113, "GBK", MultiByte, "GBK Simplified Chinese",
+		func() CharacterEncoding { return whatwg_gb18030{whatwg_index("gb18030"), true} }},
+	{"gb18030", nil,
+		114, "gb18030", MultiByte, "GB 18030 Chinese",
+		func() CharacterEncoding { return whatwg_gb18030{whatwg_index("gb18030"), false} }},
+	{"Big5", []string{"big5-hkscs", "cn-big5", "csbig5", "x-x-big5"},
+		2026, "Big5", MultiByte, "Big5 Traditional Chinese with HKSCS",
+		func() CharacterEncoding { return &whatwg_big5{index: whatwg_index("big5")} }},
+	{"EUC-JP", []string{"cseucpkdfmtjapanese", "x-euc-jp"},
+		18, "EUC-JP", MultiByte, "EUC-JP Japanese",
+		func() CharacterEncoding { return whatwg_euc_jp{whatwg_index("jis0208"), whatwg_index("jis0212")} }},
+	{"ISO-2022-JP", []string{"csiso2022jp"},
+		39, "ISO-2022-JP", Stateful, "ISO-2022-JP Japanese",
+		func() CharacterEncoding { return &whatwg_iso2022_jp{jis0208: whatwg_index("jis0208"), katakana: whatwg_index("iso-2022-jp-katakana")} }},
+	{"Shift_JIS", []string{"csshiftjis", "ms932", "ms_kanji", "shift-jis", "sjis", "windows-31j", "x-sjis"},
+		17, "Shift_JIS", MultiByte, "Shift_JIS Japanese with Microsoft extensions",
+		func() CharacterEncoding { return whatwg_shift_jis{whatwg_index("shift_jis")} }},
+	{"EUC-KR", []string{"cseuckr", "csksc56011987", "iso-ir-149", "korean", "ks_c_5601-1987", "ks_c_5601-1989", "ksc5601",
+		"ksc_5601", "windows-949"},
+		38, "EUC-KR", MultiByte, "EUC-KR Korean with Unified Hangul Code extensions",
+		func() CharacterEncoding { return whatwg_euc_kr{whatwg_index("euc-kr")} }},
+	{"replacement", []string{"csiso2022kr", "hz-gb-2312", "iso-2022-cn", "iso-2022-cn-ext", "iso-2022-kr"},
+		0, "replacement", Stateful, "Replacement of unsafe encodings (decoded text is one error)",
+		func() CharacterEncoding { return &whatwg_replacement{} }},
+	{"UTF-16BE", []string{"unicodefffe"},
+		1013, "UTF-16BE", MultiByte, "Unicode UTF-16 big endian", get_UTF16BE},
+	{"UTF-16LE", []string{"csunicode", "iso-10646-ucs-2", "ucs-2", "unicode", "unicodefeff", "utf-16"},
+		1014, "UTF-16LE", MultiByte, "Unicode UTF-16 little endian", get_UTF16LE},
+	{"x-user-defined", nil,
+		0, "x-user-defined", SingleByte, "Bytes 0x80..0xFF as private use characters U+F780..U+F7FF",
+		func() CharacterEncoding { return whatwg_user_defined{} }},
+}
+
+var whatwg_labels = make(map[string]*Encoding)
+
+func init() {
+	for i := range(whatwg_encodings) {
+		e := &whatwg_encodings[i]
+		whatwg_labels[ascii_lower(e.Name)] = e
+		for _, l := range(e.Aliases) {
+			whatwg_labels[l] = e
+		}
+	}
+}
+
+// ASCII case insensitive comparison is used for labels
+func ascii_lower(s string) string {
+	b := []byte(s)
+	for i := range(b) {
+		if b[i] >= 'A' && b[i] <= 'Z' {
+			b[i] += 'a' - 'A'
+		}
+	}
+
+	return string(b)
+}
+
+// WHATWGLookup resolves label like browsers do: leading and trailing whitespace is ignored and labels are
+// ASCII case insensitive ("  Latin1" is windows-1252). Returns nil for unknown labels.
+func WHATWGLookup(label string) *Encoding {
+	return whatwg_labels[ascii_lower(string(bytes.Trim([]byte(label), "\t\n\f\r ")))]
+}
+
+// Decoder which checks byte order mark at the beginning of the input. UTF-8 and UTF-16 byte order marks
+// override encoding of label and are skipped.
+type whatwg_decoder struct {
+	decoder RuneDecoder // Decoder of label
+	current RuneDecoder // Decoder selected by byte order mark
+	sniffed bool        // Byte order mark has checked
+}
+
+var whatwg_boms = []struct {
+	bom     string
+	decoder RuneDecoder
+}{
+	{"\xef\xbb\xbf", whatwg_utf8{}},
+	{"\xfe\xff", enc_UTF16BE{}},
+	{"\xff\xfe", enc_UTF16LE{}},
+}
+
+// Check if p is the beginning of byte order mark and more bytes are needed
+func partial_bom(p []byte) bool {
+	for _, b := range(whatwg_boms) {
+		if len(p) < len(b.bom) && b.bom[:len(p)] == string(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (self *whatwg_decoder) DecodeRune(p []byte) (rune, int) {
+	if !self.sniffed {
+		if partial_bom(p) {
+			return RuneError, 0
+		}
+
+		self.sniffed = true
+		for _, b := range(whatwg_boms) {
+			if bytes.HasPrefix(p, []byte(b.bom)) {
+				self.current = b.decoder
+				return NoRune, len(b.bom)
+			}
+		}
+	}
+
+	return self.current.DecodeRune(p)
+}
+
+func (self *whatwg_decoder) FullRune(p []byte) bool {
+	if !self.sniffed && partial_bom(p) {
+		return false
+	}
+
+	return self.current.FullRune(p)
+}
+
+func (self *whatwg_decoder) Reset() {
+	self.current = self.decoder
+	self.sniffed = false
+	reset_decoder(self.decoder)
+}
+
+// NewWHATWGDecoder creates decoder which works like browsers do: label is resolved by WHATWGLookup, WHATWG indexes
+// are used and byte order mark overrides the label. Decoder returns errors as other decoders, use
+// ReplaceWith(RuneError) handler to get the same text as browsers. Note that input consisting of the beginning of
+// byte order mark only ("\xEF\xBB") is reported as incomplete sequence.
+func NewWHATWGDecoder(label string) RuneDecoder {
+	e := WHATWGLookup(label)
+	if e == nil {
+		return nil
+	}
+
+	decoder := e.New()
+	return &whatwg_decoder{decoder: decoder, current: decoder}
+}
+
+// NewWHATWGEncoder creates encoder for label resolved by WHATWGLookup. Like browsers do, replacement, UTF-16BE
+// and UTF-16LE encodings are encoded as UTF-8.
+func NewWHATWGEncoder(label string) RuneEncoder {
+	e := WHATWGLookup(label)
+	if e == nil {
+		return nil
+	}
+
+	switch e.Name {
+	case "replacement", "UTF-16BE", "UTF-16LE":
+		return enc_UTF8{}
+	}
+
+	return e.New()
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sort"
 	"fmt"
+	"path/filepath"
 )
 
 func get_locale() string {
@@ -18,9 +19,41 @@ func get_locale() string {
 type cmdline struct {
 	from_enc, to_enc string
 	list bool
+	whatwg bool
 	output string
-	flags int
+	handler charenc.ErrorHandler
 	inputs []string
+	charmaps charmap_files
+}
+
+// Charmap files given by -charmap options
+type charmap_files []string
+
+func (self *charmap_files) String() string {
+	return strings.Join(*self, ",")
+}
+
+func (self *charmap_files) Set(value string) error {
+	*self = append(*self, value)
+	return nil
+}
+
+// Register charmaps by file name without extension, <code_set_name> and aliases of the file
+func load_charmaps(files []string) {
+	for _, path := range(files) {
+		cm, e := charenc.LoadCharmap(path)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", e.Error())
+			os.Exit(1)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		aliases := cm.Aliases()
+		if cm.Name() != "" {
+			aliases = append([]string{cm.Name()}, aliases...)
+		}
+		charenc.RegisterCharmap(cm, name, aliases...)
+	}
 }
 
 func parse_cmdline() cmdline {
@@ -28,9 +61,9 @@ func parse_cmdline() cmdline {
 	var replace, ignore bool
 
 	locale := get_locale()
-	flag.StringVar(&r.from_enc, "from-code", locale, "convert characters from encoding.")
+	flag.StringVar(&r.from_enc, "from-code", locale, "convert characters from encoding (add //IGNORE to skip invalid sequences, ,swaplfnl to decode EBCDIC NL as LF).")
 	flag.StringVar(&r.from_enc, "f", locale, "convert characters from encoding (short version).")
-	flag.StringVar(&r.to_enc, "to-code", locale, "convert characters to encoding. If not specified the encoding corresponding to current locale is used")
+	flag.StringVar(&r.to_enc, "to-code", locale, "convert characters to encoding (add //TRANSLIT to transliterate and //IGNORE to skip unrepresentable characters). If not specified the encoding corresponding to current locale is used")
 	flag.StringVar(&r.to_enc, "t", locale, "convert characters to encoding (short version). ")
 	flag.BoolVar(&r.list, "list", false, "list known code character sets.")
 	flag.BoolVar(&r.list, "l", false, "list known code character sets (short version).")
@@ -38,6 +71,8 @@ func parse_cmdline() cmdline {
 	flag.StringVar(&r.output, "o", "", "specify output file (default is stdout) (short version).")
 	flag.BoolVar(&replace, "r", false, "replace invalid characters in input and output streams")
 	flag.BoolVar(&ignore, "c", false, "ignore invalid characters in input and output streams")
+	flag.BoolVar(&r.whatwg, "whatwg", false, "resolve encodings like browsers do (WHATWG Encoding Standard): \"latin1\" is windows-1252, byte order mark overrides input encoding.")
+	flag.Var(&r.charmaps, "charmap", "load encoding from Unicode.org mapping file, glibc charmap or ICU .ucm file (can be repeated). Encoding is named by file name without extension.")
 	var help bool
 	flag.BoolVar(&help, "help", false, "print this help and exit")
 	flag.BoolVar(&help, "h", false, "print this help and exit (short version)")
@@ -52,38 +87,36 @@ func parse_cmdline() cmdline {
 
 	r.inputs = flag.Args()
 	if ignore {
-		r.flags |= charenc.IgnoreErrors
+		r.handler = charenc.IgnoreErrors
 	}
 	if replace {
-		r.flags |= charenc.ReplaceErrors
+		r.handler = charenc.ReplaceErrors
 	}
 
 	return r
 }
 
+// Print canonical names of encodings with descriptions and aliases
 func print_list() {
-	array := charenc.ListEncodings()
-	sort.Sort(sort.StringSlice(array))
-	col := 0
-	for i := range(array) {
-		if i > 0 {
-			print(", ")
-			col += 2
+	list := charenc.DefaultRegistry.Encodings()
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToUpper(list[i].Name) < strings.ToUpper(list[j].Name)
+	})
+
+	for _, e := range(list) {
+		line := fmt.Sprintf("%-16s %s", strings.ToUpper(e.Name), e.Description)
+		if len(e.Aliases) > 0 {
+			line += " (" + strings.ToUpper(strings.Join(e.Aliases, ", ")) + ")"
 		}
-		if col + len(array[i]) > 72 {
-			println("")
-			col = 0
-		}
-		print(strings.ToUpper(array[i]))
-		col += len(array[i])
+		fmt.Println(line)
 	}
-	println("")
 	os.Exit(0)
 }
 
 func main() {
 	// Parse command line:
 	params := parse_cmdline()
+	load_charmaps(params.charmaps)
 
 	if params.list {
 		print_list()
@@ -125,7 +158,16 @@ func main() {
 		}
 	}
 
-	reader := charenc.GetReader(stdin, params.from_enc, params.to_enc, params.flags)
+	var reader *charenc.Reader
+	if params.whatwg {
+		decoder := charenc.NewWHATWGDecoder(params.from_enc)
+		encoder := charenc.NewWHATWGEncoder(params.to_enc)
+		if decoder != nil && encoder != nil {
+			reader = charenc.NewReader(stdin, decoder, encoder, params.handler)
+		}
+	} else {
+		reader = charenc.GetReader(stdin, params.from_enc, params.to_enc, params.handler)
+	}
 	if reader == nil {
 		fmt.Fprintf(os.Stderr, "Error: can not create converter\n")
 		os.Exit(1)
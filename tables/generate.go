@@ -0,0 +1,892 @@
+//go:build ignore
+// +build ignore
+
+// Generator of charenc tables. Reads mapping files and writes tables8.go, tablesmb.go, tablestr.go, tablesinfo.go
+// and tableswhatwg.go:
+//
+//	aliases.txt                    canonical names of encodings followed by aliases
+//	charsets.txt                   MIBenum, WHATWG name and description of encodings
+//	mappings/<name>.TXT            8-bit encoding in Unicode.org format (0xXX<tab>0xXXXX)
+//	mappings/index-<name>.txt      8-bit encoding in WHATWG index format (pointer of byte 0x80+pointer)
+//	mappings/mb/<name>.TXT         multibyte encoding in Unicode.org format (0xXXXX+0xXXXX for two characters)
+//	mappings/mb/<name>-encode.TXT  codes used by encoder instead of the first code of character
+//	mappings/mb/<name>-decode.TXT  codes which are decoded but never used by encoder
+//	bestfit/bestfitNNNN.txt        Microsoft Windows best fit tables
+//	whatwg/index-<name>.txt        single byte encodings and CJK indexes of WHATWG Encoding Standard
+//	whatwg/index-gb18030-ranges.txt  GB18030 four bytes ranges, shared by WHATWG gb18030 and GB18030
+//	unicode/UnicodeData.txt        Unicode character database (decompositions and combining marks)
+//
+// Usage: go generate charenc (or go run generate.go -src <tables> -out <charenc>).
+// With -check the generator doesn't write anything and fails if generated files differ from existing ones.
+// With -tests <dir> test files for goconv are written to dir.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Multibyte sequences: range of the first byte and ranges of following bytes.
+// All other bytes present in mapping file are single byte characters.
+type mbrange struct {
+	first [2]byte
+	rest [][2]byte
+}
+
+var SJIS = []mbrange{
+	{[2]byte{0x81, 0x9f}, [][2]byte{{0x40, 0xfc}}},
+	{[2]byte{0xe0, 0xfc}, [][2]byte{{0x40, 0xfc}}}}
+
+var EUC_JP = []mbrange{
+	{[2]byte{0x8e, 0x8e}, [][2]byte{{0xa1, 0xfe}}},
+	{[2]byte{0x8f, 0x8f}, [][2]byte{{0xa1, 0xfe}, {0xa1, 0xfe}}},
+	{[2]byte{0xa1, 0xfe}, [][2]byte{{0xa1, 0xfe}}}}
+
+var EUC_CN = []mbrange{
+	{[2]byte{0xa1, 0xfe}, [][2]byte{{0xa1, 0xfe}}}}
+
+// GB18030 four bytes codes are calculated using ranges, see gb18030_ranges
+var GBK = []mbrange{
+	{[2]byte{0x81, 0xfe}, [][2]byte{{0x40, 0xfe}}}}
+
+var BIG5 = []mbrange{
+	{[2]byte{0x81, 0xfe}, [][2]byte{{0x40, 0xfe}}}}
+
+var EUC_KR = []mbrange{
+	{[2]byte{0xa1, 0xfe}, [][2]byte{{0xa1, 0xfe}}}}
+
+// CNS 11643 plane 1 is written as two bytes, all planes as 8E A1..B0 followed by two bytes
+var EUC_TW = []mbrange{
+	{[2]byte{0x8e, 0x8e}, [][2]byte{{0xa1, 0xb0}, {0xa1, 0xfe}, {0xa1, 0xfe}}},
+	{[2]byte{0xa1, 0xfe}, [][2]byte{{0xa1, 0xfe}}}}
+
+var UHC = []mbrange{
+	{[2]byte{0x81, 0xfe}, [][2]byte{{0x41, 0xfe}}}}
+
+var JOHAB = []mbrange{
+	{[2]byte{0x84, 0xd3}, [][2]byte{{0x41, 0xfe}}},
+	{[2]byte{0xd8, 0xf9}, [][2]byte{{0x31, 0xfe}}}}
+
+// ISO 6937 and T.61: non-spacing diacritic followed by base letter (or space for spacing diacritic)
+var ISO6937 = []mbrange{
+	{[2]byte{0xc1, 0xc8}, [][2]byte{{0x20, 0x7f}}},
+	{[2]byte{0xca, 0xcb}, [][2]byte{{0x20, 0x7f}}},
+	{[2]byte{0xcd, 0xcf}, [][2]byte{{0x20, 0x7f}}}}
+
+var MB_TABLES = map[string][]mbrange{
+	"cp932": SJIS,
+	"shift_jis": SJIS,
+	"euc_jp": EUC_JP,
+	"euc_jis_2004": EUC_JP,
+	"euc_jisx0213": EUC_JP,
+	"gb2312": EUC_CN,
+	"gbk": GBK,
+	"gb18030": GBK,
+	"big5": BIG5,
+	"cp950": BIG5,
+	"big5hkscs": BIG5,
+	"euc_kr": EUC_KR,
+	"euc_tw": EUC_TW,
+	"cp949": UHC,
+	"johab": JOHAB,
+	"iso6937": ISO6937,
+	"iso6937_2": ISO6937,
+	"t_61": ISO6937,
+}
+
+// CJK indexes of WHATWG Encoding Standard (whatwg/index-<index>.txt) and pointers used by encoders. Encoder uses
+// the first pointer of character, pointers in exclude range are skipped and characters from last use the last one
+// (index Shift_JIS pointer and index Big5 pointer).
+var WHATWG_INDEXES = []struct {
+	name    string
+	index   string
+	encode  bool
+	exclude [2]uint32
+	last    []rune
+}{
+	{"big5", "big5", true, [2]uint32{0, (0xa1 - 0x81) * 157}, []rune{0x2550, 0x255e, 0x2561, 0x256a, 0x5341, 0x5345}},
+	{"euc-kr", "euc-kr", true, [2]uint32{}, nil},
+	{"gb18030", "gb18030", true, [2]uint32{}, nil},
+	{"iso-2022-jp-katakana", "iso-2022-jp-katakana", false, [2]uint32{}, nil},
+	{"jis0208", "jis0208", true, [2]uint32{}, nil},
+	{"jis0212", "jis0212", false, [2]uint32{}, nil},
+	{"shift_jis", "jis0208", true, [2]uint32{8272, 8836}, nil},
+}
+
+// Windows best fit tables are read from bestfit/bestfitNNNN.txt in WindowsBestFit format
+// (https://www.unicode.org/Public/MAPPINGS/VENDORS/MICSFT/WindowsBestFit/), every file must exist.
+var BESTFIT = map[string]string{
+	"cp1250": "bestfit1250.txt",
+	"cp1251": "bestfit1251.txt",
+	"cp1252": "bestfit1252.txt",
+	"cp1253": "bestfit1253.txt",
+	"cp1254": "bestfit1254.txt",
+	"cp1255": "bestfit1255.txt",
+	"cp1256": "bestfit1256.txt",
+	"cp1257": "bestfit1257.txt",
+	"cp1258": "bestfit1258.txt",
+	"cp437": "bestfit437.txt",
+	"cp850": "bestfit850.txt",
+	"cp866": "bestfit866.txt",
+}
+
+// Characters which are decomposed for transliteration (Hangul syllables are skipped):
+var TRANSLIT_RANGES = [][2]rune{{0xa0, 0xac00}, {0xd7a4, 0xd800}, {0xf900, 0x10000}, {0x1d400, 0x1d800}, {0x1f100, 0x1f200}}
+
+const HEADER = "// Code generated by tables/generate.go from mapping files. DO NOT EDIT.\n\npackage charenc\n\n"
+
+// Encoding name with aliases
+type encoding struct {
+	name string
+	aliases []string
+}
+
+// Line of mapping file: code and one or two characters
+type mapping struct {
+	code uint32
+	uchr []rune
+}
+
+type generator struct {
+	src string
+	encodings []encoding
+	decomp map[rune][]rune // decompositions without formatting tags
+	marks map[rune]bool    // combining marks
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "generate: " + format + "\n", args...)
+	os.Exit(1)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Read lines of file without comments starting from comment and split them to fields
+func read_fields(path string, comment string, sep string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		fail("%v", err)
+	}
+	defer f.Close()
+
+	var res [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, comment); comment != "" && i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if sep == "" {
+			res = append(res, strings.Fields(line))
+		} else {
+			res = append(res, strings.Split(line, sep))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		fail("%s: %v", path, err)
+	}
+
+	return res
+}
+
+func parse_hex(path, s string) uint32 {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") || strings.HasPrefix(s, "U+") {
+		s = s[2:]
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		fail("%s: invalid number %q", path, s)
+	}
+
+	return uint32(v)
+}
+
+// Read mapping file in Unicode.org format. Lines without character (undefined codes) are skipped.
+func read_mapping(path string) []mapping {
+	var res []mapping
+	for _, f := range(read_fields(path, "#", "")) {
+		if len(f) < 2 {
+			continue
+		}
+		m := mapping{parse_hex(path, f[0]), nil}
+		for _, u := range(strings.Split(f[1], "+")) {
+			m.uchr = append(m.uchr, rune(parse_hex(path, u)))
+		}
+		res = append(res, m)
+	}
+
+	return res
+}
+
+// Read index file in WHATWG format: pointer and code point
+func read_index(path string) []mbpair {
+	var res []mbpair
+	for _, f := range(read_fields(path, "#", "")) {
+		if len(f) < 2 {
+			fail("%s: invalid line %q", path, strings.Join(f, " "))
+		}
+		ptr, err := strconv.ParseUint(f[0], 10, 32)
+		if err != nil {
+			fail("%s: invalid pointer %q", path, f[0])
+		}
+		res = append(res, mbpair{uint32(ptr), rune(parse_hex(path, f[1]))})
+	}
+
+	return res
+}
+
+func (self *generator) read_aliases() {
+	for _, f := range(read_fields(filepath.Join(self.src, "aliases.txt"), "#", "")) {
+		self.encodings = append(self.encodings, encoding{f[0], f[1:]})
+	}
+}
+
+func (self *generator) read_unicode() {
+	self.decomp = make(map[rune][]rune)
+	self.marks = make(map[rune]bool)
+	path := filepath.Join(self.src, "unicode", "UnicodeData.txt")
+	for _, f := range(read_fields(path, "", ";")) {
+		if len(f) < 6 {
+			fail("%s: invalid line", path)
+		}
+		c := rune(parse_hex(path, f[0]))
+		if f[3] != "0" || f[2] == "Mn" {
+			self.marks[c] = true
+		}
+		d := strings.Fields(f[5])
+		if len(d) > 0 && strings.HasPrefix(d[0], "<") {
+			d = d[1:]
+		}
+		for _, x := range(d) {
+			self.decomp[c] = append(self.decomp[c], rune(parse_hex(path, x)))
+		}
+	}
+}
+
+// Decompose string (canonical and compatibility decompositions) and remove combining marks
+func (self *generator) strip_marks(s []rune) []rune {
+	var res []rune
+	for _, c := range(s) {
+		if d, ok := self.decomp[c]; ok {
+			res = append(res, self.strip_marks(d)...)
+		} else if !self.marks[c] {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+// Write slice of table entries, per_line entries on each line
+func write_table(w *bytes.Buffer, decl string, n int, per_line int, entry func(i int) string) {
+	w.WriteString(decl + "{")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			w.WriteString(",")
+		}
+		if i % per_line == 0 {
+			w.WriteString("\n\t")
+		} else {
+			w.WriteString(" ")
+		}
+		w.WriteString(entry(i))
+	}
+	w.WriteString("}\n\n")
+}
+
+// Read 8-bit encoding: Unicode.org mapping file or WHATWG single byte index
+func (self *generator) table8(name string) (*[256]rune, bool) {
+	var tbl [256]rune
+	path := filepath.Join(self.src, "mappings", name + ".TXT")
+	if exists(path) {
+		for _, m := range(read_mapping(path)) {
+			if m.code > 0xff || len(m.uchr) != 1 {
+				fail("%s: invalid mapping 0x%X", path, m.code)
+			}
+			tbl[m.code] = m.uchr[0]
+		}
+		return &tbl, true
+	}
+
+	path = filepath.Join(self.src, "mappings", "index-" + name + ".txt")
+	if exists(path) {
+		return read_index8(path), true
+	}
+
+	return nil, false
+}
+
+// Read WHATWG single byte index: ASCII bytes are the same and pointer is byte - 0x80
+func read_index8(path string) *[256]rune {
+	var tbl [256]rune
+	for i := 0; i < 0x80; i++ {
+		tbl[i] = rune(i)
+	}
+	for _, p := range(read_index(path)) {
+		if p.code > 0x7f {
+			fail("%s: invalid pointer %d", path, p.code)
+		}
+		tbl[0x80 + p.code] = p.uchr
+	}
+
+	return &tbl
+}
+
+// Encodings of WHATWG Encoding Standard from whatwg/index-<name>.txt: single byte encodings and CJK indexes
+func (self *generator) tableswhatwg() []byte {
+	var w bytes.Buffer
+	w.WriteString(HEADER)
+
+	cjk := make(map[string]bool)
+	for _, x := range(WHATWG_INDEXES) {
+		cjk[x.index] = true
+	}
+
+	files, _ := filepath.Glob(filepath.Join(self.src, "whatwg", "index-*.txt"))
+	sort.Strings(files)
+	var names []string
+	num := 0
+	for _, path := range(files) {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "index-"), ".txt")
+		if cjk[name] || name == "gb18030-ranges" {
+			continue
+		}
+		to_ucs, from_ucs := write_table8(&w, "wtbl", &num, read_index8(path))
+		names = append(names, fmt.Sprintf("tbls{\"%s\", \"%s\", %s, %s, nil}", name, name, to_ucs, from_ucs))
+	}
+
+	write_table(&w, "var whatwg_tables = [...]tbls", len(names), 1, func(i int) string {
+		return names[i]
+	})
+
+	// CJK indexes: code points by pointer (0 if pointer is not mapped) and pointers of encoder sorted by character
+	var indexes []string
+	decode := make(map[string]string)
+	for _, x := range(WHATWG_INDEXES) {
+		ptrs := read_index(filepath.Join(self.src, "whatwg", "index-" + x.index + ".txt"))
+		to_ucs, ok := decode[x.index]
+		if !ok {
+			var tbl []rune
+			for _, p := range(ptrs) {
+				for int(p.code) >= len(tbl) {
+					tbl = append(tbl, 0)
+				}
+				tbl[p.code] = p.uchr
+			}
+			num++
+			to_ucs = fmt.Sprintf("wtbl_%d[:]", num)
+			write_table(&w, fmt.Sprintf("var wtbl_%d = [...]rune", num), len(tbl), 16, func(i int) string {
+				return fmt.Sprintf("0x%04x", tbl[i])
+			})
+			decode[x.index] = to_ucs
+		}
+
+		from_ucs := "nil"
+		if x.encode {
+			last := make(map[rune]bool)
+			for _, r := range(x.last) {
+				last[r] = true
+			}
+			enc := make(map[rune]uint32)
+			for _, p := range(ptrs) {
+				if p.code >= x.exclude[0] && p.code < x.exclude[1] {
+					continue
+				}
+				if _, ok := enc[p.uchr]; !ok || last[p.uchr] {
+					enc[p.uchr] = p.code
+				}
+			}
+			var rev []mbpair
+			for u, c := range(enc) {
+				rev = append(rev, mbpair{c, u})
+			}
+			sort.Slice(rev, func(i, j int) bool { return rev[i].uchr < rev[j].uchr })
+
+			num++
+			from_ucs = fmt.Sprintf("wtbl_%d[:]", num)
+			write_table(&w, fmt.Sprintf("var wtbl_%d = [...]mbpair", num), len(rev), 4, func(i int) string {
+				return fmt.Sprintf("{ %d, 0x%04X }", rev[i].code, rev[i].uchr)
+			})
+		}
+
+		indexes = append(indexes, fmt.Sprintf("windex{\"%s\", %s, %s}", x.name, to_ucs, from_ucs))
+	}
+
+	write_table(&w, "var whatwg_indexes = [...]windex", len(indexes), 1, func(i int) string {
+		return indexes[i]
+	})
+
+	return bytes.TrimRight(w.Bytes(), "\n")
+}
+
+// Read best fit characters from WCTABLE section of Microsoft best fit file
+func (self *generator) bestfit_file(name string) map[rune]byte {
+	path := filepath.Join(self.src, "bestfit", name)
+	if !exists(path) {
+		fail("%s: best fit table is not found", path)
+	}
+
+	res := make(map[rune]byte)
+	section := ""
+	for _, f := range(read_fields(path, ";", "")) {
+		if !strings.HasPrefix(f[0], "0x") {
+			section = f[0]
+			continue
+		}
+		if section == "WCTABLE" && len(f) >= 2 {
+			res[rune(parse_hex(path, f[0]))] = byte(parse_hex(path, f[1]))
+		}
+	}
+
+	return res
+}
+
+// Characters which are not in the codepage and their best fit bytes, sorted by character
+func (self *generator) bestfit(name string, tbl *[256]rune) []pair {
+	if _, ok := BESTFIT[name]; !ok {
+		return nil
+	}
+
+	rev := make(map[rune]byte)
+	for i := 255; i >= 0; i-- {
+		if tbl[i] != 0 || i == 0 {
+			rev[tbl[i]] = byte(i)
+		}
+	}
+
+	wc := self.bestfit_file(BESTFIT[name])
+	var res []pair
+	for u, b := range(wc) {
+		if _, ok := rev[u]; !ok {
+			res = append(res, pair{b, u})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].uchr < res[j].uchr })
+
+	return res
+}
+
+type pair struct {
+	chr byte
+	uchr rune
+}
+
+type mbpair struct {
+	code uint32
+	uchr rune
+}
+
+// Write decoding and encoding tables of 8-bit encoding, returns their names
+func write_table8(w *bytes.Buffer, prefix string, num *int, tbl *[256]rune) (string, string) {
+	*num++
+	to_ucs := fmt.Sprintf("%s_%d", prefix, *num)
+	write_table(w, "var " + to_ucs + " = [256]rune", 256, 16, func(i int) string {
+		return fmt.Sprintf("0x%04x", tbl[i])
+	})
+
+	// RuneToByte uses binary search by character
+	rev := make([]pair, 256)
+	for i := range(rev) {
+		rev[i] = pair{byte(i), tbl[i]}
+	}
+	sort.Slice(rev, func(i, j int) bool {
+		return rev[i].uchr < rev[j].uchr || (rev[i].uchr == rev[j].uchr && rev[i].chr < rev[j].chr)
+	})
+	*num++
+	from_ucs := fmt.Sprintf("%s_%d", prefix, *num)
+	write_table(w, "var " + from_ucs + " = [256]pair", 256, 4, func(i int) string {
+		return fmt.Sprintf("{ 0x%02x, 0x%04X }", rev[i].chr, rev[i].uchr)
+	})
+
+	return to_ucs, from_ucs
+}
+
+func (self *generator) tables8() []byte {
+	var w bytes.Buffer
+	w.WriteString(HEADER)
+
+	var names []string
+	num := 0
+	for _, e := range(self.encodings) {
+		tbl, ok := self.table8(e.name)
+		if !ok {
+			continue
+		}
+
+		to_ucs, from_ucs := write_table8(&w, "tbl", &num, tbl)
+
+		bestfit := "nil"
+		if bf := self.bestfit(e.name, tbl); len(bf) > 0 {
+			num++
+			bestfit = fmt.Sprintf("tbl_%d[:]", num)
+			write_table(&w, fmt.Sprintf("var tbl_%d = [...]pair", num), len(bf), 4, func(i int) string {
+				return fmt.Sprintf("{ 0x%02x, 0x%04X }", bf[i].chr, bf[i].uchr)
+			})
+		}
+
+		for _, n := range(append([]string{e.name}, e.aliases...)) {
+			names = append(names, fmt.Sprintf("tbls{\"%s\", \"%s\", %s, %s, %s}", n, e.name, to_ucs, from_ucs, bestfit))
+		}
+	}
+
+	write_table(&w, "var names = [...]tbls", len(names), 1, func(i int) string {
+		return names[i]
+	})
+
+	return bytes.TrimRight(w.Bytes(), "\n")
+}
+
+func code_len(code uint32) int {
+	n := 1
+	for code > 0xff {
+		code >>= 8
+		n++
+	}
+
+	return n
+}
+
+func (self *generator) tablesmb() []byte {
+	var w bytes.Buffer
+	w.WriteString(HEADER)
+
+	var names []string
+	num := 0
+	gb18030 := false
+	for _, e := range(self.encodings) {
+		path := filepath.Join(self.src, "mappings", "mb", e.name + ".TXT")
+		if !exists(path) {
+			continue
+		}
+		seq, ok := MB_TABLES[e.name]
+		if !ok {
+			fail("%s: unknown multibyte sequences of %s", path, e.name)
+		}
+
+		var seqlen [256]byte
+		for _, r := range(seq) {
+			for c := int(r.first[0]); c <= int(r.first[1]); c++ {
+				seqlen[c] = byte(len(r.rest) + 1)
+			}
+		}
+
+		var to_ucs []mbpair
+		var comb []mapping
+		for _, m := range(read_mapping(path)) {
+			if m.code <= 0xff && seqlen[m.code] == 0 {
+				seqlen[m.code] = 1
+			}
+			if int(seqlen[m.code >> uint(8 * (code_len(m.code) - 1))]) != code_len(m.code) {
+				fail("%s: invalid code 0x%X", path, m.code)
+			}
+			switch len(m.uchr) {
+			case 1:
+				to_ucs = append(to_ucs, mbpair{m.code, m.uchr[0]})
+			case 2:
+				comb = append(comb, m)
+			default:
+				fail("%s: too many characters for code 0x%X", path, m.code)
+			}
+		}
+		sort.Slice(to_ucs, func(i, j int) bool { return to_ucs[i].code < to_ucs[j].code })
+		sort.Slice(comb, func(i, j int) bool { return comb[i].code < comb[j].code })
+
+		// Encoder uses the first code of character if it is not overridden by exceptions files
+		decode_only := make(map[uint32]bool)
+		if path = filepath.Join(self.src, "mappings", "mb", e.name + "-decode.TXT"); exists(path) {
+			for _, m := range(read_mapping(path)) {
+				decode_only[m.code] = true
+			}
+		}
+		enc := make(map[rune]uint32)
+		for _, p := range(to_ucs) {
+			if _, ok := enc[p.uchr]; !ok && !decode_only[p.code] {
+				enc[p.uchr] = p.code
+			}
+		}
+		if path = filepath.Join(self.src, "mappings", "mb", e.name + "-encode.TXT"); exists(path) {
+			for _, m := range(read_mapping(path)) {
+				enc[m.uchr[0]] = m.code
+			}
+		}
+		var from_ucs []mbpair
+		for u, c := range(enc) {
+			from_ucs = append(from_ucs, mbpair{c, u})
+		}
+		sort.Slice(from_ucs, func(i, j int) bool { return from_ucs[i].uchr < from_ucs[j].uchr })
+
+		num++
+		nm0 := fmt.Sprintf("mbtbl_%d", num)
+		write_table(&w, "var " + nm0 + " = [...]byte", 256, 16, func(i int) string {
+			return fmt.Sprintf("%d", seqlen[i])
+		})
+		num++
+		nm1 := fmt.Sprintf("mbtbl_%d", num)
+		write_table(&w, "var " + nm1 + " = [...]mbpair", len(to_ucs), 4, func(i int) string {
+			return fmt.Sprintf("{ 0x%04x, 0x%04X }", to_ucs[i].code, to_ucs[i].uchr)
+		})
+		num++
+		nm2 := fmt.Sprintf("mbtbl_%d", num)
+		write_table(&w, "var " + nm2 + " = [...]mbpair", len(from_ucs), 4, func(i int) string {
+			return fmt.Sprintf("{ 0x%04x, 0x%04X }", from_ucs[i].code, from_ucs[i].uchr)
+		})
+		nm3 := "nil"
+		if len(comb) > 0 {
+			num++
+			nm3 = fmt.Sprintf("mbtbl_%d[:]", num)
+			write_table(&w, fmt.Sprintf("var mbtbl_%d = [...]mbcomb", num), len(comb), 2, func(i int) string {
+				return fmt.Sprintf("{ 0x%04x, [2]rune{ 0x%04X, 0x%04X } }", comb[i].code, comb[i].uchr[0], comb[i].uchr[1])
+			})
+		}
+
+		for _, n := range(append([]string{e.name}, e.aliases...)) {
+			names = append(names, fmt.Sprintf("mbtbls{\"%s\", \"%s\", &%s, %s[:], %s[:], %s}", n, e.name, nm0, nm1, nm2, nm3))
+		}
+		gb18030 = gb18030 || e.name == "gb18030"
+	}
+
+	if gb18030 {
+		ranges := read_index(filepath.Join(self.src, "whatwg", "index-gb18030-ranges.txt"))
+		write_table(&w, "var gb18030_ranges = [...]mbpair", len(ranges), 4, func(i int) string {
+			return fmt.Sprintf("{ %d, 0x%04X }", ranges[i].code, ranges[i].uchr)
+		})
+	}
+
+	write_table(&w, "var mbnames = [...]mbtbls", len(names), 1, func(i int) string {
+		return names[i]
+	})
+
+	return bytes.TrimRight(w.Bytes(), "\n")
+}
+
+// Metadata of encodings from charsets.txt. Every encoding from aliases.txt must be described.
+func (self *generator) tablesinfo() []byte {
+	known := make(map[string]bool)
+	for _, e := range(self.encodings) {
+		known[e.name] = true
+	}
+
+	var info []string
+	path := filepath.Join(self.src, "charsets.txt")
+	for _, f := range(read_fields(path, "#", "\t")) {
+		if len(f) != 4 {
+			fail("%s: invalid line %q", path, strings.Join(f, "\t"))
+		}
+		if !known[f[0]] {
+			fail("%s: %s is not in aliases.txt", path, f[0])
+		}
+		delete(known, f[0])
+		mib, err := strconv.Atoi(f[1])
+		if err != nil {
+			fail("%s: invalid MIBenum %q", path, f[1])
+		}
+		if f[2] == "-" {
+			f[2] = ""
+		}
+		info = append(info, fmt.Sprintf("{\"%s\", %d, \"%s\", %s}", f[0], mib, f[2], go_string([]rune(f[3]))))
+	}
+	for _, e := range(self.encodings) {
+		if known[e.name] {
+			fail("%s: %s is not described", path, e.name)
+		}
+	}
+
+	var w bytes.Buffer
+	w.WriteString(HEADER)
+	write_table(&w, "var charsets = [...]charset", len(info), 1, func(i int) string {
+		return info[i]
+	})
+
+	return bytes.TrimRight(w.Bytes(), "\n")
+}
+
+func go_string(s []rune) string {
+	res := "\""
+	for _, c := range(s) {
+		if c == '"' || c == '\\' {
+			res += "\\" + string(c)
+		} else if c > 0xffff {
+			res += fmt.Sprintf("\\U%08X", c)
+		} else if c < 0x20 || c > 0x7e {
+			res += fmt.Sprintf("\\u%04X", c)
+		} else {
+			res += string(c)
+		}
+	}
+
+	return res + "\""
+}
+
+func (self *generator) tablestr() []byte {
+	type trpair struct {
+		uchr rune
+		repl []rune
+	}
+
+	var tbl []trpair
+	for _, r := range(TRANSLIT_RANGES) {
+		for c := r[0]; c < r[1]; c++ {
+			s := self.strip_marks([]rune{c})
+			if len(s) == 0 || (len(s) == 1 && s[0] == c) {
+				continue
+			}
+			tbl = append(tbl, trpair{c, s})
+		}
+	}
+
+	var w bytes.Buffer
+	w.WriteString(HEADER)
+	w.WriteString("// Characters without combining marks, sorted by character\n")
+	write_table(&w, "var translit_decomp = [...]trpair", len(tbl), 4, func(i int) string {
+		return fmt.Sprintf("{ 0x%04X, %s }", tbl[i].uchr, go_string(tbl[i].repl))
+	})
+
+	return bytes.TrimRight(w.Bytes(), "\n")
+}
+
+// Write test files for goconv: characters of 8-bit encodings in several Unicode encodings
+func (self *generator) tests(dir string) {
+	cps := []string{"utf-8", "utf-16le", "utf-16be", "utf-32le", "utf-32be"}
+	var script bytes.Buffer
+	script.WriteString(`#!/bin/sh
+
+T=/tmp/test-goconv.$$
+if [ "x$GOCONV" = x ]; then
+    GOCONV=../goconv/goconv
+fi
+
+error()
+{
+    echo "Error: $*" 1>&2
+    exit 1
+}
+
+test()
+{
+    "$GOCONV" -f "$1" -t "$2" -o "$T" "test_$1_ORIG.txt" || error "$1 -> $2"
+    diff test_$1_$2.txt "$T" || error "$1 -> $2: invalid"
+    rm -f "$T"
+}
+
+`)
+
+	for _, e := range(self.encodings) {
+		tbl, ok := self.table8(e.name)
+		if !ok {
+			continue
+		}
+
+		var orig []byte
+		var text []rune
+		for i := 32; i < 256; i++ {
+			if tbl[i] != 0 {
+				orig = append(orig, byte(i))
+				text = append(text, tbl[i])
+			}
+		}
+
+		write_file(filepath.Join(dir, "test_" + e.name + "_ORIG.txt"), orig)
+		for _, cp := range(cps) {
+			var out []byte
+			switch cp {
+			case "utf-8":
+				out = []byte(string(text))
+			case "utf-16le", "utf-16be":
+				for _, c := range(utf16.Encode(text)) {
+					out = append_be(out, uint32(c), 2, cp == "utf-16le")
+				}
+			default:
+				for _, c := range(text) {
+					out = append_be(out, uint32(c), 4, cp == "utf-32le")
+				}
+			}
+			write_file(filepath.Join(dir, "test_" + e.name + "_" + cp + ".txt"), out)
+			script.WriteString(fmt.Sprintf("test %s %s\n", e.name, cp))
+		}
+	}
+
+	write_file(filepath.Join(dir, "test.sh"), script.Bytes())
+}
+
+// Append n bytes of v in big endian or little endian byte order
+func append_be(out []byte, v uint32, n int, le bool) []byte {
+	for i := 0; i < n; i++ {
+		if le {
+			out = append(out, byte(v >> uint(8 * i)))
+		} else {
+			out = append(out, byte(v >> uint(8 * (n - 1 - i))))
+		}
+	}
+
+	return out
+}
+
+func write_file(path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		fail("%v", err)
+	}
+}
+
+func main() {
+	var out, tests string
+	var check bool
+	g := &generator{}
+	flag.StringVar(&g.src, "src", ".", "directory with aliases.txt and mapping files.")
+	flag.StringVar(&out, "out", ".", "directory of charenc package.")
+	flag.BoolVar(&check, "check", false, "check that generated files are up to date instead of writing them.")
+	flag.StringVar(&tests, "tests", "", "write test files for goconv to this directory.")
+	flag.Parse()
+
+	g.read_aliases()
+	g.read_unicode()
+
+	if tests != "" {
+		g.tests(tests)
+		return
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"tables8.go", g.tables8()},
+		{"tablesmb.go", g.tablesmb()},
+		{"tablestr.go", g.tablestr()},
+		{"tablesinfo.go", g.tablesinfo()},
+		{"tableswhatwg.go", g.tableswhatwg()},
+	}
+
+	outdated := false
+	for _, f := range(files) {
+		path := filepath.Join(out, f.name)
+		data := append(f.data, '\n')
+		if !check {
+			write_file(path, data)
+			continue
+		}
+		old, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Equal(old, data) {
+			fmt.Fprintf(os.Stderr, "generate: %s is not up to date\n", path)
+			outdated = true
+		}
+	}
+
+	if outdated {
+		os.Exit(1)
+	}
+}